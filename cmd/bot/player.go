@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/bot"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// player runs a bot over its own channel, which every subscription of the
+// bot consumes from and every message it sends is published on.
+type player struct {
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	game     string
+	team     string
+	bot      *bot.Bot
+	interval time.Duration
+	kicked   chan routing.KickNotice
+}
+
+// run registers the bot, joins the game and plays until ctx is done or the
+// bot is kicked.
+func (p *player) run(ctx context.Context) error {
+	username := p.bot.Username
+	p.kicked = make(chan routing.KickNotice, 1)
+	p.bot.Game = p.game
+
+	token, err := lobby.RegisterPlayer(p.conn, username)
+	if err != nil {
+		return err
+	}
+	defer lobby.UnregisterPlayer(p.conn, username, token)
+	p.bot.Token = token
+	stopHeartbeats := make(chan struct{})
+	defer close(stopHeartbeats)
+	go func() {
+		if err := lobby.SendHeartbeats(p.conn, username, stopHeartbeats); err != nil {
+			log.Printf("[%s] %v", username, err)
+		}
+	}()
+
+	joined, err := lobby.JoinGame(p.conn, username, token, p.game, p.team)
+	if err != nil {
+		return err
+	}
+	p.bot.SetRules(joined.Rules)
+	p.bot.World.SetSource(lobby.RemoteArmies{Conn: p.conn, Game: p.game, Username: username})
+	p.bot.GS.SetBank(lobby.RemoteBank{Conn: p.conn, Game: p.game, Username: username, Token: token})
+	p.bot.GS.SetTreasury(joined.Treasury)
+	p.bot.GS.SetDiplomacy(joined.Diplomacy)
+	defer lobby.LeaveGame(p.conn, username, token, p.game)
+
+	// Closing the channel, before leaving, stops the bot from hearing of or
+	// sending anything in a game it is no longer in.
+	p.ch, err = p.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("couldn't open channel: %v", err)
+	}
+	defer p.ch.Close()
+	p.bot.Publisher = pubsub.ChannelPublisher{Ch: p.ch}
+
+	p.bot.GS.Subscribe(p.logger())
+	if err := p.subscribe(); err != nil {
+		return err
+	}
+	log.Printf("[%s] playing %s as %s with a budget of %d", username, p.game, p.bot.Strategy(), p.bot.Budget())
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case kn := <-p.kicked:
+			log.Printf("[%s] removed from %s: %s", username, kn.Game, kn.Reason)
+			return nil
+		case <-ticker.C:
+			if err := p.bot.Play(); err != nil {
+				log.Printf("[%s] %v", username, err)
+			}
+		}
+	}
+}
+
+func (p *player) subscribe() error {
+	username := p.bot.Username
+	if err := pubsub.SubscribeJSONOn(
+		p.ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(p.game, routing.PauseKey, username),
+		routing.GameKey(p.game, routing.PauseKey),
+		pubsub.QueueTypeTransient,
+		p.bot.HandlerPause(),
+	); err != nil {
+		return err
+	}
+
+	if err := pubsub.SubscribeJSONOn(
+		p.ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(p.game, routing.RoundKey, username),
+		routing.GameKey(p.game, routing.RoundKey),
+		pubsub.QueueTypeTransient,
+		p.bot.HandlerRound(),
+	); err != nil {
+		return err
+	}
+	state, round, err := lobby.FetchState(p.conn, username, p.game)
+	if err != nil {
+		return err
+	}
+	p.bot.GS.HandlePause(state)
+	p.bot.GS.SyncRound(round)
+
+	if err := pubsub.SubscribeJSONOn(
+		p.ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(p.game, routing.KickKey, username),
+		routing.GameKey(p.game, routing.KickKey, username),
+		pubsub.QueueTypeTransient,
+		p.handlerKick(),
+	); err != nil {
+		return err
+	}
+
+	if err := pubsub.SubscribeJSONOn(
+		p.ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(p.game, routing.TreasuryKey, username),
+		routing.GameKey(p.game, routing.TreasuryKey, username),
+		pubsub.QueueTypeTransient,
+		p.bot.HandlerTreasury(),
+	); err != nil {
+		return err
+	}
+
+	if err := pubsub.SubscribeJSONOn(
+		p.ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(p.game, routing.DiplomacyKey, username),
+		routing.GameKey(p.game, routing.DiplomacyKey),
+		pubsub.QueueTypeTransient,
+		p.bot.HandlerDiplomacy(),
+	); err != nil {
+		return err
+	}
+
+	if err := pubsub.SubscribeJSONOn(
+		p.ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(p.game, routing.GameOverKey, username),
+		routing.GameKey(p.game, routing.GameOverKey),
+		pubsub.QueueTypeTransient,
+		p.bot.HandlerGameOver(),
+	); err != nil {
+		return err
+	}
+
+	if p.bot.GS.Rules().Fog {
+		err = pubsub.SubscribeJSONOn(
+			p.ch,
+			routing.ExchangePerilDirect,
+			routing.GameKey(p.game, routing.SightingsKey, username),
+			routing.GameKey(p.game, routing.SightingsKey, username),
+			pubsub.QueueTypeTransient,
+			p.bot.HandlerSighting(),
+		)
+	} else {
+		err = pubsub.SubscribeJSONOn(
+			p.ch,
+			routing.ExchangePerilTopic,
+			routing.GameKey(p.game, routing.ArmyMovesPrefix, username),
+			routing.GameKey(p.game, routing.ArmyMovesPrefix, "*"),
+			pubsub.QueueTypeTransient,
+			p.bot.HandlerMove(),
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := pubsub.SubscribeJSONOn(
+		p.ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(p.game, routing.RejectionsKey, username),
+		routing.GameKey(p.game, routing.RejectionsKey, username),
+		pubsub.QueueTypeTransient,
+		p.bot.HandlerRejection(),
+	); err != nil {
+		return err
+	}
+
+	warKey := routing.GameKey(p.game, routing.WarRecognitionsPrefix)
+	if p.bot.GS.Rules().Fog {
+		warKey = routing.GameKey(p.game, routing.WarRecognitionsPrefix, username)
+	}
+	return pubsub.SubscribeJSONOn(
+		p.ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(p.game, routing.WarRecognitionsPrefix, username),
+		warKey,
+		pubsub.QueueTypeTransient,
+		p.bot.HandlerWar(),
+	)
+}
+
+func (p *player) handlerKick() func(routing.KickNotice) pubsub.AckType {
+	return func(kn routing.KickNotice) pubsub.AckType {
+		select {
+		case p.kicked <- kn:
+		default:
+		}
+		return pubsub.AckTypeAck
+	}
+}
+
+// logger prints a one-line summary of the events worth following when many
+// bots share a terminal.
+func (p *player) logger() gamelogic.Subscriber {
+	username := p.bot.Username
+	return gamelogic.SubscriberFunc(func(e gamelogic.Event) {
+		switch e := e.(type) {
+		case gamelogic.UnitSpawned:
+			log.Printf("[%s] spawned %s %d in %s, %d point(s) left", username, e.Unit.Rank, e.Unit.ID, e.Unit.Location, p.bot.Budget())
+		case gamelogic.TreasuryChanged:
+			log.Printf("[%s] has %d gold after round %d", username, e.Treasury.Gold, e.Treasury.Round)
+		case gamelogic.UnitsMoved:
+			log.Printf("[%s] moved %d unit(s) to %s", username, len(e.Move.Units), e.Move.ToLocation)
+		case gamelogic.OrdersSubmitted:
+			log.Printf("[%s] submitted %d order(s) for round %d", username, e.Moves, e.Round)
+		case gamelogic.PauseChanged:
+			log.Printf("[%s] paused: %v", username, e.Paused)
+		case gamelogic.WarResolved:
+			if e.Outcome == gamelogic.WarOutcomeNotInvolved || e.Outcome == gamelogic.WarOutcomeNoUnits {
+				break
+			}
+			for _, result := range e.Results {
+				log.Printf("[%s] %s", username, result.Battle.Summary())
+			}
+		case gamelogic.GameEnded:
+			log.Printf("[%s] game over, %s won: %s", username, strings.Join(e.Over.Winners, ", "), e.Over.Reason)
+		}
+	})
+}
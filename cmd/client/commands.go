@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type client struct {
+	conn      *amqp.Connection
+	publishCh *amqp.Channel
+	username  string
+	token     string
+	game      string
+	gs        *gamelogic.GameState
+	world     *gamelogic.World
+	intel     *gamelogic.Intel
+	out       io.Writer
+	prompt    bool
+	kicked    chan routing.KickNotice
+}
+
+// repaintPrompt redraws the line prompt after a message handler printed
+// something in the middle of it.
+func (c *client) repaintPrompt() {
+	if c.prompt {
+		fmt.Fprint(c.out, "> ")
+	}
+}
+
+// execute runs a single command typed by the player and reports whether
+// the client should quit. Failed commands return an error.
+func (c *client) execute(words []string) (bool, error) {
+	switch words[0] {
+	case "spawn":
+		if err := c.gs.CommandSpawn(words); err != nil {
+			return false, err
+		}
+	case "move":
+		move, err := c.gs.CommandMove(words)
+		if err != nil {
+			return false, err
+		}
+		if c.gs.IsTurnBased() {
+			return false, nil
+		}
+		// The server checks the move before the other players hear of it,
+		// and only needs the units moved.
+		intent := c.gs.Intent(move)
+		intent.Token = c.token
+		if err = pubsub.PublishJSON(
+			c.publishCh,
+			routing.ExchangePerilDirect,
+			routing.GameKey(c.game, routing.MoveIntentsPrefix, c.username),
+			intent,
+		); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(c.out, "Move was published successfully")
+	case "submit":
+		orders, err := c.gs.CommandSubmit()
+		if err != nil {
+			return false, err
+		}
+		orders.Game = c.game
+		orders.Token = c.token
+		if err = pubsub.PublishJSON(
+			c.publishCh,
+			routing.ExchangePerilDirect,
+			routing.GameKey(c.game, routing.OrdersPrefix, c.username),
+			orders,
+		); err != nil {
+			return false, err
+		}
+	case "status":
+		c.gs.CommandStatus()
+	case "map":
+		c.gs.CommandMap()
+	case "units":
+		c.gs.CommandUnits()
+	case "treasury":
+		c.gs.CommandTreasury()
+	case "economy":
+		c.gs.CommandEconomy()
+	case "propose", "accept", "break":
+		req, err := c.gs.CommandNegotiate(words)
+		if err != nil {
+			return false, err
+		}
+		req.Game = c.game
+		req.Token = c.token
+		// The new pacts arrive on the diplomacy queue like everyone else's.
+		if _, err := lobby.Negotiate(c.conn, req); err != nil {
+			return false, err
+		}
+	case "diplomacy":
+		c.gs.CommandDiplomacy()
+	case "intel":
+		if !c.gs.Rules().Fog {
+			return false, errors.New("the game is not played in fog of war, every move shows the whole army")
+		}
+		c.intel.Fprint(c.out, time.Now())
+	case "say", "whisper", "team":
+		msg, err := c.gs.CommandChat(words)
+		if err != nil {
+			return false, err
+		}
+		msg.Game, msg.Token = c.game, c.token
+		if err := pubsub.PublishJSON(
+			c.publishCh,
+			routing.ExchangePerilDirect,
+			routing.ChatSendKey,
+			msg,
+		); err != nil {
+			return false, err
+		}
+	case "history":
+		if err := c.gs.CommandHistory(words); err != nil {
+			return false, err
+		}
+	case "army":
+		if err := c.gs.CommandArmy(words); err != nil {
+			return false, err
+		}
+	case "help":
+		gamelogic.FprintClientHelp(c.out)
+	case "spam":
+		if len(words) < 2 {
+			return false, errors.New("usage: spam <number>")
+		}
+		num, err := strconv.Atoi(words[1])
+		if err != nil {
+			return false, errors.New("the provided argument is not an integer")
+		}
+		for range num {
+			maliciousLog := gamelogic.GetMaliciousLog()
+			if err := publishGameLog(
+				c.publishCh,
+				c.game,
+				routing.GameLog{
+					CurrentTime: time.Now(),
+					Message:     maliciousLog,
+					Username:    c.username,
+				},
+			); err != nil {
+				return false, errors.New("couldn't publish spam log")
+			}
+		}
+	case "quit":
+		gamelogic.FprintQuit(c.out)
+		return true, nil
+	default:
+		gamelogic.FprintClientHelp(c.out)
+		return false, fmt.Errorf("unknown command: %s", words[0])
+	}
+	return false, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func (c *client) handlerPause() func(routing.PlayingState) pubsub.AckType {
+	return func(ps routing.PlayingState) pubsub.AckType {
+		defer c.repaintPrompt()
+		c.gs.HandlePause(ps)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (c *client) handlerRound() func(routing.RoundState) pubsub.AckType {
+	return func(rs routing.RoundState) pubsub.AckType {
+		defer c.repaintPrompt()
+		c.gs.HandleRound(rs)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (c *client) handlerTreasury() func(routing.Treasury) pubsub.AckType {
+	return func(t routing.Treasury) pubsub.AckType {
+		defer c.repaintPrompt()
+		c.gs.HandleTreasury(t)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (c *client) handlerDiplomacy() func(routing.DiplomacyState) pubsub.AckType {
+	return func(state routing.DiplomacyState) pubsub.AckType {
+		defer c.repaintPrompt()
+		c.gs.HandleDiplomacy(state)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (c *client) handlerGameOver() func(routing.GameOver) pubsub.AckType {
+	return func(over routing.GameOver) pubsub.AckType {
+		defer c.repaintPrompt()
+		c.gs.HandleGameOver(over)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (c *client) handlerChat() func(routing.ChatMessage) pubsub.AckType {
+	return func(msg routing.ChatMessage) pubsub.AckType {
+		// Chat is a single line, start it under the prompt rather than
+		// after it.
+		if c.prompt {
+			fmt.Fprintln(c.out)
+		}
+		defer c.repaintPrompt()
+		c.gs.HandleChat(msg)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (c *client) handlerKick() func(routing.KickNotice) pubsub.AckType {
+	return func(kn routing.KickNotice) pubsub.AckType {
+		fmt.Fprintln(c.out)
+		fmt.Fprintf(c.out, "You have been removed from %s: %s\n", kn.Game, kn.Reason)
+		gamelogic.FprintQuit(c.out)
+		// One kick ends the session, a second one has nothing left to end.
+		select {
+		case c.kicked <- kn:
+		default:
+		}
+		return pubsub.AckTypeAck
+	}
+}
+
+func (c *client) handlerMove() func(gamelogic.ArmyMove) pubsub.AckType {
+	return func(mv gamelogic.ArmyMove) pubsub.AckType {
+		defer c.repaintPrompt()
+		if mv.Player.Username != c.username {
+			var err error
+			if mv, err = c.world.ApplyMove(mv); err != nil {
+				fmt.Fprintf(c.out, "Lost track of the army of %s: %v\n", mv.Player.Username, err)
+			}
+		}
+		switch c.gs.HandleMove(mv) {
+		case gamelogic.MoveOutcomeMakeWar:
+			// The server fights the war with the armies it knows.
+			decl := c.gs.DeclareWar(mv.Player)
+			decl.Token = c.token
+			if err := pubsub.PublishJSON(
+				c.publishCh,
+				routing.ExchangePerilDirect,
+				routing.GameKey(c.game, routing.WarDeclarationsPrefix, c.username),
+				decl,
+			); err != nil {
+				return pubsub.AckTypeNackRequeue
+			}
+			return pubsub.AckTypeAck
+		case gamelogic.MoveOutComeSafe:
+			return pubsub.AckTypeAck
+		}
+		return pubsub.AckTypeNackDiscard
+	}
+}
+
+// handlerSighting handles the moves the server relays in fog of war, and
+// keeps what they show of the enemy armies in the client's intel.
+func (c *client) handlerSighting() func(gamelogic.Sighting) pubsub.AckType {
+	handleMove := c.handlerMove()
+	return func(s gamelogic.Sighting) pubsub.AckType {
+		c.intel.Observe(s)
+		if s.Scouted {
+			c.world.ApplyMove(s.Move)
+			return pubsub.AckTypeAck
+		}
+		return handleMove(s.Move)
+	}
+}
+
+// handlerRejection takes back a move the server refused, putting the
+// player's units where the server has them.
+func (c *client) handlerRejection() func(gamelogic.Rejection) pubsub.AckType {
+	return func(r gamelogic.Rejection) pubsub.AckType {
+		defer c.repaintPrompt()
+		c.gs.HandleRejection(r)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (c *client) handlerWar() func(gamelogic.RecognitionOfWar) pubsub.AckType {
+	return func(rw gamelogic.RecognitionOfWar) pubsub.AckType {
+		defer c.repaintPrompt()
+		outcome, results := c.gs.HandleWar(rw)
+		switch outcome {
+		case gamelogic.WarOutcomeNotInvolved:
+			return pubsub.AckTypeAck
+
+		case gamelogic.WarOutcomeNoUnits:
+			return pubsub.AckTypeNackDiscard
+
+		case gamelogic.WarOutcomeOpponentWon, gamelogic.WarOutcomeYouWon, gamelogic.WarOutcomeDraw:
+			for _, result := range results {
+				c.intel.Forget([]gamelogic.Battle{result.Battle})
+			}
+			// Every side hears of the war, the attacker logs it.
+			if rw.Attacker.Username != c.username {
+				return pubsub.AckTypeAck
+			}
+			for _, result := range results {
+				if err := publishGameLog(
+					c.publishCh,
+					c.game,
+					routing.GameLog{
+						CurrentTime: time.Now(),
+						Message:     result.Battle.Summary(),
+						Username:    c.username,
+					},
+				); err != nil {
+					return pubsub.AckTypeNackRequeue
+				}
+			}
+			return pubsub.AckTypeAck
+
+		default:
+			fmt.Fprintln(c.out, "unknown war outcome")
+			return pubsub.AckTypeNackDiscard
+		}
+	}
+}
+
+func publishGameLog(ch *amqp.Channel, game string, gl routing.GameLog) error {
+	if err := pubsub.PublishGob(
+		ch,
+		routing.ExchangePerilTopic,
+		routing.GameKey(game, routing.GameLogSlug, gl.Username),
+		gl,
+	); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func joinLobby(conn *amqp.Connection, username, token string) (lobby.Joined, bool) {
+	for {
+		input := gamelogic.GetInput()
+		if len(input) == 0 {
+			continue
+		}
+
+		switch input[0] {
+		case "games":
+			games, err := lobby.ListGames(conn, username)
+			if err != nil {
+				fmt.Println(err.Error())
+				continue
+			}
+			gamelogic.PrintGames(games)
+		case "join":
+			if len(input) < 2 {
+				fmt.Println("Usage: join <game> [team]")
+				continue
+			}
+			team := ""
+			if len(input) > 2 {
+				team = input[2]
+			}
+			joined, err := joinGame(conn, username, token, input[1], team)
+			if err != nil {
+				fmt.Println(err.Error())
+				continue
+			}
+			return joined, true
+		case "help":
+			gamelogic.PrintLobbyHelp()
+		case "quit":
+			return lobby.Joined{}, false
+		default:
+			fmt.Println("Unknown command: " + input[0])
+			gamelogic.PrintLobbyHelp()
+		}
+	}
+}
+
+func joinGame(conn *amqp.Connection, username, token, name, team string) (lobby.Joined, error) {
+	joined, err := lobby.JoinGame(conn, username, token, name, team)
+	if err != nil {
+		return lobby.Joined{}, err
+	}
+	game, rules := joined.Game, joined.Rules
+	fmt.Printf("You joined %s (%s) on map %s with %s units, players: %v\n", game.Name, game.Status, rules.Map.Name, rules.Catalog.Name, game.Players)
+	if team := game.Teams[username]; team != "" {
+		fmt.Printf("You are on team %s\n", team)
+	}
+	fmt.Printf("You have %d gold\n", joined.Treasury.Gold)
+	if conditions := rules.Victory.Conditions(); len(conditions) > 0 {
+		fmt.Printf("To win: %s\n", strings.Join(conditions, ", or "))
+	}
+	if rules.Fog {
+		fmt.Println("The game is played in fog of war: you only see enemy units in or next to your locations")
+	}
+	return joined, nil
+}
+
+func leaveGame(conn *amqp.Connection, game, username, token string) {
+	if _, err := lobby.LeaveGame(conn, username, token, game); err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+func registerPlayer(conn *amqp.Connection) (string, string, error) {
+	for {
+		username, err := gamelogic.ClientWelcome()
+		if err != nil {
+			return "", "", err
+		}
+		token, err := lobby.RegisterPlayer(conn, username)
+		if err != nil {
+			if !errors.Is(err, lobby.ErrRejected) {
+				return "", "", err
+			}
+			fmt.Println(err.Error())
+			continue
+		}
+		return username, token, nil
+	}
+}
+
+func unregisterPlayer(conn *amqp.Connection, username, token string) {
+	if err := lobby.UnregisterPlayer(conn, username, token); err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+func sendHeartbeats(conn *amqp.Connection, username string) {
+	if err := lobby.SendHeartbeats(conn, username, nil); err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+func syncState(conn *amqp.Connection, gs *gamelogic.GameState, game string) error {
+	state, round, err := lobby.FetchState(conn, gs.GetUsername(), game)
+	if err != nil {
+		return err
+	}
+	gs.HandlePause(state)
+	gs.SyncRound(round)
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/script"
+)
+
+func loadScript(path, exec string) ([]script.Statement, error) {
+	statements := []script.Statement{}
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open script: %v", err)
+		}
+		defer f.Close()
+		fromFile, err := script.Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		statements = append(statements, fromFile...)
+	}
+	if exec != "" {
+		fromExec, err := script.ParseString(exec)
+		if err != nil {
+			return nil, fmt.Errorf("-exec: %v", err)
+		}
+		statements = append(statements, fromExec...)
+	}
+	return statements, nil
+}
+
+// eventRecorder queues every game event so that an expect statement also
+// matches events that happened while earlier statements were running. The
+// queue is unbounded, so no event is ever missed, however long a script
+// runs without expecting any.
+type eventRecorder struct {
+	events  []gamelogic.Event
+	arrived chan struct{}
+	mu      *sync.Mutex
+}
+
+func newEventRecorder() *eventRecorder {
+	return &eventRecorder{
+		events:  []gamelogic.Event{},
+		arrived: make(chan struct{}, 1),
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (r *eventRecorder) Notify(e gamelogic.Event) {
+	r.mu.Lock()
+	r.events = append(r.events, e)
+	r.mu.Unlock()
+	select {
+	case r.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// expect waits for an event named name, dropping the events queued before
+// it.
+func (r *eventRecorder) expect(name string, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		if r.take(name) {
+			return nil
+		}
+		select {
+		case <-r.arrived:
+		case <-timer.C:
+			return fmt.Errorf("timed out after %v waiting for %s", timeout, name)
+		}
+	}
+}
+
+func (r *eventRecorder) take(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.events {
+		if e.EventName() == name {
+			r.events = r.events[i+1:]
+			return true
+		}
+	}
+	r.events = r.events[:0]
+	return false
+}
+
+// runScript runs every statement in order and stops at the first one that
+// fails, or once the player is kicked.
+func (c *client) runScript(statements []script.Statement, events *eventRecorder) error {
+	for _, s := range statements {
+		select {
+		case kn := <-c.kicked:
+			return fmt.Errorf("line %d: removed from %s: %s", s.Line, kn.Game, kn.Reason)
+		default:
+		}
+		fmt.Fprintf(c.out, "> %s\n", s)
+		switch s.Kind {
+		case script.KindWait:
+			time.Sleep(s.Duration)
+		case script.KindExpect:
+			if err := events.expect(s.Event, s.Duration); err != nil {
+				return fmt.Errorf("line %d: %v", s.Line, err)
+			}
+		case script.KindCommand:
+			quit, err := c.execute(s.Words)
+			if err != nil {
+				return fmt.Errorf("line %d: %s: %v", s.Line, s, err)
+			}
+			if quit {
+				return nil
+			}
+		default:
+			return errors.New("unknown statement")
+		}
+	}
+	return nil
+}
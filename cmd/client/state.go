@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/snapshot"
+)
+
+func restoreState(store *snapshot.Store, gs *gamelogic.GameState, game string, out io.Writer) error {
+	snap, entries, ok, err := store.Load(game, gs.GetUsername())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	gs.Resume(snap, entries)
+	path, _ := store.JournalPath(game, gs.GetUsername())
+	fmt.Fprintf(out, "Restored %d unit(s) from %s\n", len(gs.GetPlayerSnap().Units), path)
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const maxFeedLines = 500
+
+var (
+	paneStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	titleStyle  = lipgloss.NewStyle().Bold(true)
+	headerStyle = lipgloss.NewStyle().Reverse(true).Padding(0, 1)
+)
+
+const feedBuffer = 1024
+
+type feedMsg string
+
+type kickedMsg struct{}
+
+// feedWriter turns everything written to it into lines for the event feed.
+// It buffers lines until the UI is running, so nothing printed while the
+// client starts up is lost.
+type feedWriter struct {
+	partial string
+	lines   chan string
+	mu      *sync.Mutex
+}
+
+func newFeedWriter() *feedWriter {
+	return &feedWriter{
+		lines: make(chan string, feedBuffer),
+		mu:    &sync.Mutex{},
+	}
+}
+
+func (f *feedWriter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.partial += string(p)
+	for {
+		i := strings.IndexByte(f.partial, '\n')
+		if i == -1 {
+			return len(p), nil
+		}
+		line := strings.TrimRight(f.partial[:i], " ")
+		f.partial = f.partial[i+1:]
+		if line != "" {
+			f.push(line)
+		}
+	}
+}
+
+// push queues a line for the feed without ever blocking: commands run while
+// the UI is busy, so when the buffer is full, e.g. after history on a long
+// game, the oldest line is dropped. The feed only keeps the latest lines
+// anyway.
+func (f *feedWriter) push(line string) {
+	select {
+	case f.lines <- line:
+		return
+	default:
+	}
+	select {
+	case <-f.lines:
+	default:
+	}
+	f.lines <- line
+}
+
+type tuiModel struct {
+	c      *client
+	feedCh <-chan string
+	input  textinput.Model
+	feed   []string
+	width  int
+	height int
+	// quit is set when the player quits, as opposed to being kicked.
+	quit bool
+}
+
+// runTUI takes over the terminal until the player quits. Game events and
+// command output are written to the event feed, so incoming messages never
+// interrupt the command line.
+func runTUI(c *client, feed *feedWriter) error {
+	final, err := tea.NewProgram(newTUIModel(c, feed.lines), tea.WithAltScreen()).Run()
+	if err != nil {
+		return err
+	}
+	// The feed goes away with the alternate screen, so the goodbye is
+	// printed again where the player can see it.
+	if m, ok := final.(tuiModel); ok && m.quit {
+		gamelogic.PrintQuit()
+	}
+	return nil
+}
+
+func waitForFeed(lines <-chan string) tea.Cmd {
+	return func() tea.Msg {
+		return feedMsg(<-lines)
+	}
+}
+
+func waitForKick(kicked <-chan routing.KickNotice) tea.Cmd {
+	return func() tea.Msg {
+		<-kicked
+		return kickedMsg{}
+	}
+}
+
+func newTUIModel(c *client, feedCh <-chan string) tuiModel {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Placeholder = "type a command, e.g. spawn europe infantry"
+	input.Focus()
+	return tuiModel{
+		c:      c,
+		feedCh: feedCh,
+		input:  input,
+		feed:   []string{"Type help to see the possible commands."},
+	}
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, waitForFeed(m.feedCh), waitForKick(m.c.kicked))
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.input.Width = msg.Width - 4
+		return m, nil
+
+	case feedMsg:
+		m.feed = append(m.feed, string(msg))
+		if len(m.feed) > maxFeedLines {
+			m.feed = m.feed[len(m.feed)-maxFeedLines:]
+		}
+		return m, waitForFeed(m.feedCh)
+
+	case kickedMsg:
+		return m, tea.Quit
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			m.quit = true
+			return m, tea.Quit
+		case tea.KeyEnter:
+			line := m.input.Value()
+			m.input.SetValue("")
+			words := strings.Fields(line)
+			if len(words) == 0 {
+				return m, nil
+			}
+			m.feed = append(m.feed, "> "+line)
+			quit, err := m.c.execute(words)
+			if err != nil {
+				m.feed = append(m.feed, err.Error())
+			}
+			if quit {
+				m.quit = true
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m tuiModel) View() string {
+	if m.width == 0 {
+		return "Loading..."
+	}
+
+	paused := "running"
+	if m.c.gs.IsPaused() {
+		paused = "paused"
+	}
+	header := headerStyle.Width(m.width).Render(fmt.Sprintf("Peril - %s playing %s (%s)", m.c.username, m.c.game, paused))
+
+	player := m.c.gs.GetPlayerSnap()
+	mapLines := m.mapLines(player)
+	unitLines := unitLines(player)
+	paneHeight := max(len(mapLines), len(unitLines)) + 1
+	paneWidth := m.width/2 - 2
+	top := lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		paneStyle.Width(paneWidth).Height(paneHeight).Render(titleStyle.Render("World")+"\n"+strings.Join(mapLines, "\n")),
+		paneStyle.Width(paneWidth).Height(paneHeight).Render(titleStyle.Render("Your units")+"\n"+strings.Join(unitLines, "\n")),
+	)
+
+	feedHeight := max(m.height-lipgloss.Height(header)-lipgloss.Height(top)-3, 1)
+	feed := m.feed
+	if len(feed) > feedHeight {
+		feed = feed[len(feed)-feedHeight:]
+	}
+
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		top,
+		lipgloss.NewStyle().Height(feedHeight).Render(strings.Join(feed, "\n")),
+		"",
+		m.input.View(),
+	)
+}
+
+// mapLines lists, for every location, your own units and the last known
+// strength of every other army seen in army moves.
+func (m tuiModel) mapLines(player gamelogic.Player) []string {
+	others := m.c.world.Players()
+	lines := []string{}
+	for _, loc := range m.c.gs.Map().Locations() {
+		parts := []string{}
+		if n := countUnitsIn(player, loc); n > 0 {
+			parts = append(parts, fmt.Sprintf("you %d", n))
+		}
+		for _, p := range others {
+			if n := countUnitsIn(p, loc); n > 0 {
+				parts = append(parts, fmt.Sprintf("%s %d", p.Username, n))
+			}
+		}
+		if len(parts) == 0 {
+			parts = append(parts, "-")
+		}
+		lines = append(lines, fmt.Sprintf("%-11s %s", loc, strings.Join(parts, ", ")))
+	}
+	return lines
+}
+
+func unitLines(player gamelogic.Player) []string {
+	if len(player.Units) == 0 {
+		return []string{"No units yet, try spawn."}
+	}
+	units := []gamelogic.Unit{}
+	for _, unit := range player.Units {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	lines := []string{}
+	for _, unit := range units {
+		lines = append(lines, fmt.Sprintf("%d: %s in %s", unit.ID, unit.Rank, unit.Location))
+	}
+	return lines
+}
+
+func countUnitsIn(p gamelogic.Player, loc gamelogic.Location) int {
+	n := 0
+	for _, unit := range p.Units {
+		if unit.Location == loc {
+			n++
+		}
+	}
+	return n
+}
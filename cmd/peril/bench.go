@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// runBench compares the bytes every move takes as a whole snapshot, as
+// moves used to be published, and as the deltas they are published as now.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	armies := fs.String("armies", "5,20,50,200", "comma-separated army sizes to measure")
+	moves := fs.Int("moves", 200, "moves to make with every army")
+	spawnEvery := fs.Int("spawn-every", 5, "spawn a unit every this many moves, 0 to never spawn")
+	seed := fs.Int64("seed", 1, "random seed")
+	fs.Parse(args)
+	if *moves <= 0 || *spawnEvery < 0 {
+		return errors.New("usage: peril bench [-armies n,n] [-moves n] [-spawn-every n] [-seed n]")
+	}
+
+	fmt.Printf("%8s %12s %12s %8s\n", "units", "snapshot", "delta", "saved")
+	for _, field := range strings.Split(*armies, ",") {
+		size, err := strconv.Atoi(field)
+		if err != nil || size <= 0 {
+			return fmt.Errorf("%q is not an army size", field)
+		}
+		whole, delta, err := benchArmy(size, *moves, *spawnEvery, rand.New(rand.NewSource(*seed)))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%8d %10d B %10d B %7.1f%%\n", size, whole / *moves, delta / *moves, 100-100*float64(delta)/float64(whole))
+	}
+	fmt.Printf("Bytes per move, averaged over %d moves. Deltas still send a snapshot every %d moves.\n", *moves, gamelogic.SnapshotInterval)
+	return nil
+}
+
+// benchArmy plays moves with an army of size units and returns the bytes
+// they took as snapshots and as deltas, stamped like the server does. Every
+// delta is rebuilt by a world, which must find the army the mover has.
+func benchArmy(size, moves, spawnEvery int, rng *rand.Rand) (int, int, error) {
+	gs := gamelogic.NewGameState("bench")
+	board := gs.Map()
+	locations := board.Locations()
+	spawn := func() error {
+		loc := locations[rng.Intn(len(locations))]
+		return gs.CommandSpawn([]string{"spawn", string(loc), gamelogic.RankInfantry})
+	}
+	for range size {
+		if err := spawn(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var sent gamelogic.SentArmy
+	world := gamelogic.NewWorld()
+	wholeBytes, deltaBytes := 0, 0
+	for i := range moves {
+		if spawnEvery > 0 && i > 0 && i%spawnEvery == 0 {
+			if err := spawn(); err != nil {
+				return 0, 0, err
+			}
+		}
+		units := gs.GetPlayerSnap().Units
+		ids := []int{}
+		for id := range units {
+			ids = append(ids, id)
+		}
+		first := ids[rng.Intn(len(ids))]
+		from := units[first].Location
+		neighbors := board.Neighbors(from)
+		words := []string{"move", string(neighbors[rng.Intn(len(neighbors))])}
+		for _, id := range ids {
+			if units[id].Location == from && len(words) < 5 {
+				words = append(words, strconv.Itoa(id))
+			}
+		}
+
+		mv, err := gs.CommandMove(words)
+		if err != nil {
+			return 0, 0, err
+		}
+		mv = sent.Stamp(mv)
+		rebuilt, err := world.ApplyMove(mv)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !sameArmy(rebuilt.Player, gs.GetPlayerSnap()) {
+			return 0, 0, fmt.Errorf("move %d of an army of %d rebuilt the wrong army", mv.Version, size)
+		}
+
+		snapshot := gamelogic.ArmyMove{Player: rebuilt.Player, Units: rebuilt.Units, ToLocation: rebuilt.ToLocation}
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return 0, 0, err
+		}
+		wholeBytes += len(data)
+		data, err = json.Marshal(mv)
+		if err != nil {
+			return 0, 0, err
+		}
+		deltaBytes += len(data)
+	}
+	return wholeBytes, deltaBytes, nil
+}
+
+func sameArmy(a, b gamelogic.Player) bool {
+	if len(a.Units) != len(b.Units) {
+		return false
+	}
+	for id, unit := range a.Units {
+		if b.Units[id] != unit {
+			return false
+		}
+	}
+	return true
+}
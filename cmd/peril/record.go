@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/recorder"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rulesKey files the rules of a game in a recording. Nothing is published
+// on it, the recorder fetches the rules from the lobby.
+const rulesKey = "recorded_rules"
+
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	out := fs.String("o", "peril.rec", "file the recording is appended to")
+	fs.Parse(args)
+
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("couldn't open recording: %v", err)
+	}
+	defer f.Close()
+
+	conn, err := amqp.Dial(connectionString)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to RabbitMQ: %v", err)
+	}
+	defer conn.Close()
+
+	w := recorder.NewWriter(f)
+	rec, err := recorder.Start(conn, w)
+	if err != nil {
+		return err
+	}
+	defer rec.Close()
+	for _, key := range []string{
+		routing.LobbyListKey,
+		routing.LobbyJoinKey,
+		routing.LobbyLeaveKey,
+		routing.LobbyStateKey,
+		routing.LobbyRulesKey,
+		routing.TreasurySpendKey,
+		routing.DiplomacyRequestKey,
+		routing.ArmyRequestKey,
+		routing.PlayerRegisterKey,
+		routing.PlayerUnregisterKey,
+	} {
+		if err := rec.BindDirect(key); err != nil {
+			return err
+		}
+	}
+
+	// The direct keys of a game are bound when the lobby announces it or
+	// one of its players, and for the games that already exist.
+	announced := make(chan routing.GameSummary, 16)
+	if err := pubsub.SubscribeJSON(
+		conn,
+		routing.ExchangePerilTopic,
+		routing.GameKey("recorder", strconv.FormatInt(time.Now().UnixNano(), 36), routing.LobbyGamesKey),
+		routing.LobbyGamesKey,
+		pubsub.QueueTypeTransient,
+		func(game routing.GameSummary) pubsub.AckType {
+			announced <- game
+			return pubsub.AckTypeAck
+		},
+	); err != nil {
+		return fmt.Errorf("couldn't follow the lobby: %v", err)
+	}
+	games, err := lobby.ListGames(conn, "recorder")
+	if err != nil {
+		return err
+	}
+	ruled := map[string]bool{}
+	for _, game := range games {
+		if err := bindGame(rec, game); err != nil {
+			return err
+		}
+		if err := recordRules(conn, w, game.Name, ruled); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Printf("Recording to %s, press Ctrl+C to stop.\n", *out)
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Recording stopped.")
+			return nil
+		case game := <-announced:
+			if err := bindGame(rec, game); err != nil {
+				fmt.Println(err.Error())
+			}
+			if err := recordRules(conn, w, game.Name, ruled); err != nil {
+				fmt.Println(err.Error())
+			}
+		}
+	}
+}
+
+// bindGame binds the direct keys of a game and of its players. Keys
+// already bound are skipped.
+func bindGame(rec *recorder.Recorder, game routing.GameSummary) error {
+	keys := []string{
+		routing.GameKey(game.Name, routing.PauseKey),
+		routing.GameKey(game.Name, routing.RoundKey),
+		routing.GameKey(game.Name, routing.DiplomacyKey),
+		routing.GameKey(game.Name, routing.GameOverKey),
+		routing.GameKey(game.Name, routing.WarRecognitionsPrefix),
+	}
+	for _, username := range game.Players {
+		keys = append(keys,
+			routing.GameKey(game.Name, routing.KickKey, username),
+			routing.GameKey(game.Name, routing.TreasuryKey, username),
+			routing.GameKey(game.Name, routing.SightingsKey, username),
+			routing.GameKey(game.Name, routing.MoveIntentsPrefix, username),
+			routing.GameKey(game.Name, routing.OrdersPrefix, username),
+			routing.GameKey(game.Name, routing.WarRecognitionsPrefix, username),
+			routing.GameKey(game.Name, routing.RejectionsKey, username),
+			routing.ChatKey(game.Name, username),
+		)
+	}
+	for _, key := range keys {
+		if err := rec.BindDirect(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordRules writes the rules of a game into the recording the first time
+// the lobby announces it, so it is replayed by the rules it was played by.
+func recordRules(conn *amqp.Connection, w *recorder.Writer, game string, ruled map[string]bool) error {
+	if ruled[game] {
+		return nil
+	}
+	rules, err := lobby.FetchRules(conn, "recorder", game)
+	if err != nil {
+		return err
+	}
+	body, err := lobby.EncodeRules(rules)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(recorder.Record{
+		RoutingKey:  routing.GameKey(game, rulesKey),
+		ContentType: "application/json",
+		Body:        body,
+		Timestamp:   time.Now(),
+	}); err != nil {
+		return err
+	}
+	ruled[game] = true
+	return nil
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/recorder"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "playback speed, e.g. 10 for ten times faster, 0 to not wait at all")
+	only := fs.String("game", "", "only replay this game")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *speed < 0 {
+		return errors.New("usage: peril replay [-speed x] [-game name] <file>")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("couldn't open recording: %v", err)
+	}
+	defer f.Close()
+	records, err := recorder.Read(f)
+	if err != nil {
+		return err
+	}
+
+	r := newReplayer(os.Stdout, *only)
+	var prev time.Time
+	for i, rec := range records {
+		if *speed > 0 && !prev.IsZero() {
+			if d := rec.Timestamp.Sub(prev); d > 0 {
+				time.Sleep(time.Duration(float64(d) / *speed))
+			}
+		}
+		prev = rec.Timestamp
+		if err := r.apply(rec); err != nil {
+			fmt.Printf("Skipping message %d (%s): %v\n", i+1, rec.RoutingKey, err)
+		}
+	}
+	r.printMaps()
+	return nil
+}
+
+type replayGame struct {
+	rules   gamelogic.Rules
+	world   *gamelogic.World
+	players map[string]*gamelogic.GameState
+}
+
+// replayer rebuilds a GameState for every player seen in a recording and
+// feeds it the messages that player received, so each one prints the same
+// outcomes its client printed. Spawns are never published, so a player's
+// army is taken from the last snapshot of it that crossed the wire. Games
+// are played by the rules the recorder fetched for them, or by the classic
+// rules in recordings without them.
+type replayer struct {
+	out   io.Writer
+	only  string
+	games map[string]*replayGame
+}
+
+func newReplayer(out io.Writer, only string) *replayer {
+	return &replayer{
+		out:   out,
+		only:  only,
+		games: map[string]*replayGame{},
+	}
+}
+
+func (r *replayer) apply(rec recorder.Record) error {
+	parts := strings.SplitN(rec.RoutingKey, ".", 3)
+	if len(parts) < 2 {
+		return nil
+	}
+	name, kind := parts[0], parts[1]
+	if r.only != "" && name != r.only {
+		return nil
+	}
+	stamp := rec.Timestamp.Format(time.TimeOnly)
+
+	switch kind {
+	case rulesKey:
+		rules, err := gamelogic.ParseRules(rec.Body)
+		if err != nil {
+			return err
+		}
+		g := r.game(name)
+		g.rules = rules
+		g.world.SetRules(rules)
+		for _, gs := range g.sorted() {
+			gs.SetRules(rules)
+		}
+
+	case routing.PauseKey:
+		var ps routing.PlayingState
+		if err := rec.Decode(&ps); err != nil {
+			return err
+		}
+		for _, gs := range r.game(name).sorted() {
+			gs.HandlePause(ps)
+		}
+
+	case routing.RoundKey:
+		var rs routing.RoundState
+		if err := rec.Decode(&rs); err != nil {
+			return err
+		}
+		for _, gs := range r.game(name).sorted() {
+			gs.HandleRound(rs)
+		}
+
+	case routing.TreasuryKey:
+		var t routing.Treasury
+		if err := rec.Decode(&t); err != nil {
+			return err
+		}
+		r.player(name, t.Username).HandleTreasury(t)
+
+	case routing.DiplomacyKey:
+		var state routing.DiplomacyState
+		if err := rec.Decode(&state); err != nil {
+			return err
+		}
+		for _, gs := range r.game(name).sorted() {
+			gs.HandleDiplomacy(state)
+		}
+
+	case routing.ChatPrefix:
+		// The server delivers every message to each of its recipients.
+		if len(parts) < 3 {
+			return nil
+		}
+		var msg routing.ChatMessage
+		if err := rec.Decode(&msg); err != nil {
+			return err
+		}
+		for _, gs := range r.game(name).sorted() {
+			if gs.GetUsername() == parts[2] {
+				gs.HandleChat(msg)
+			}
+		}
+
+	case routing.GameOverKey:
+		var over routing.GameOver
+		if err := rec.Decode(&over); err != nil {
+			return err
+		}
+		for _, gs := range r.game(name).sorted() {
+			gs.HandleGameOver(over)
+		}
+
+	case routing.SightingsKey:
+		// In fog of war every player only hears of what they saw of a
+		// move, and the mover of their whole move as the server checked it.
+		var s gamelogic.Sighting
+		if err := rec.Decode(&s); err != nil {
+			return err
+		}
+		if s.Scouted {
+			return nil
+		}
+		g := r.game(name)
+		gs := r.player(name, s.Viewer)
+		mv := s.Move
+		if mv.Player.Username == s.Viewer {
+			mv, _ = g.world.ApplyMove(mv)
+			if p, ok := g.world.Player(s.Viewer); ok {
+				gs.Restore(p)
+			}
+		}
+		gs.HandleMove(mv)
+
+	case routing.MoveIntentsPrefix:
+		// Moves only count once the server publishes them.
+		return nil
+
+	case routing.RejectionsKey:
+		// The mover's units go back to where the server has them.
+		if len(parts) < 3 {
+			return nil
+		}
+		var rej gamelogic.Rejection
+		if err := rec.Decode(&rej); err != nil {
+			return err
+		}
+		r.player(name, parts[2]).HandleRejection(rej)
+
+	case routing.ArmyMovesPrefix:
+		var mv gamelogic.ArmyMove
+		if err := rec.Decode(&mv); err != nil {
+			return err
+		}
+		g := r.game(name)
+		// A recording started mid-game misses moves, the armies catch up
+		// on the next snapshots.
+		mv, _ = g.world.ApplyMove(mv)
+		r.player(name, mv.Player.Username)
+		for _, gs := range g.sorted() {
+			if p, ok := g.world.Player(gs.GetUsername()); ok {
+				gs.Restore(p)
+			}
+			gs.HandleMove(mv)
+		}
+
+	case routing.WarRecognitionsPrefix:
+		var rw gamelogic.RecognitionOfWar
+		if err := rec.Decode(&rw); err != nil {
+			return err
+		}
+		g := r.game(name)
+		if len(parts) == 3 {
+			// In fog of war every player who sees the war hears of it,
+			// the defender always.
+			r.player(name, parts[2]).HandleWar(rw)
+			if parts[2] == rw.Defender.Username {
+				g.world.ApplyWar(rw)
+			}
+			return nil
+		}
+		// Wars only carry the units fighting, the armies come from the
+		// world. Every side, allies included, loses its own units.
+		for _, side := range append([]gamelogic.Player{rw.Attacker, rw.Defender}, rw.Allies...) {
+			gs := r.player(name, side.Username)
+			if p, ok := g.world.Player(side.Username); ok {
+				gs.Restore(p)
+			}
+			gs.HandleWar(rw)
+		}
+		g.world.ApplyWar(rw)
+
+	case routing.OrdersPrefix:
+		var orders gamelogic.OrderSet
+		if err := rec.Decode(&orders); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "%s [%s] %s submitted %d order(s) for round %d\n", stamp, name, orders.Username, len(orders.Moves), orders.Round)
+
+	case routing.GameLogSlug:
+		var gl routing.GameLog
+		if err := rec.Decode(&gl); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "%s [%s] log from %s: %s\n", stamp, name, gl.Username, gl.Message)
+
+	case routing.KickKey:
+		var kn routing.KickNotice
+		if err := rec.Decode(&kn); err != nil {
+			return err
+		}
+		username := ""
+		if len(parts) == 3 {
+			username = parts[2]
+		}
+		fmt.Fprintf(r.out, "%s [%s] %s was removed: %s\n", stamp, name, username, kn.Reason)
+	}
+	return nil
+}
+
+func (r *replayer) game(name string) *replayGame {
+	g, ok := r.games[name]
+	if !ok {
+		g = &replayGame{
+			rules:   gamelogic.ClassicRules(),
+			world:   gamelogic.NewWorld(),
+			players: map[string]*gamelogic.GameState{},
+		}
+		g.world.SetRules(g.rules)
+		r.games[name] = g
+	}
+	return g
+}
+
+func (r *replayer) player(game, username string) *gamelogic.GameState {
+	g := r.game(game)
+	gs, ok := g.players[username]
+	if !ok {
+		gs = gamelogic.NewGameState(username)
+		gs.SetRules(g.rules)
+		gs.Subscribe(gamelogic.NewTextRenderer(&prefixWriter{
+			w:      r.out,
+			prefix: fmt.Sprintf("[%s/%s] ", game, username),
+			start:  true,
+		}))
+		g.players[username] = gs
+	}
+	return gs
+}
+
+func (g *replayGame) sorted() []*gamelogic.GameState {
+	players := []*gamelogic.GameState{}
+	for _, gs := range g.players {
+		players = append(players, gs)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].GetUsername() < players[j].GetUsername()
+	})
+	return players
+}
+
+func (r *replayer) printMaps() {
+	names := []string{}
+	for name := range r.games {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.out, "\n==== Final map of %s ====\n", name)
+		r.games[name].world.PrintMap()
+	}
+}
+
+// prefixWriter starts every line with prefix, so the output of several
+// replayed players can be told apart.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	start  bool
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, c := range b {
+		if p.start {
+			buf.WriteString(p.prefix)
+			p.start = false
+		}
+		buf.WriteByte(c)
+		if c == '\n' {
+			p.start = true
+		}
+	}
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
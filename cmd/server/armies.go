@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// serveArmies tells players and spectators who missed a move the army of
+// its player, as the server knows it.
+func serveArmies(conn *amqp.Connection, games *lobby.Lobby) error {
+	return pubsub.ServeJSON(
+		conn,
+		routing.ExchangePerilDirect,
+		routing.ArmyRequestKey,
+		routing.ArmyRequestKey,
+		func(req routing.ArmyRequest) routing.ArmyResponse {
+			army, err := games.Army(req.Game, req.Of)
+			if err != nil {
+				return routing.ArmyResponse{Error: err.Error()}
+			}
+			data, err := json.Marshal(army)
+			if err != nil {
+				return routing.ArmyResponse{Error: fmt.Sprintf("couldn't marshal the army: %v", err)}
+			}
+			return routing.ArmyResponse{Army: data}
+		},
+	)
+}
+
+// trackers are the channels the server follows its games on, by name.
+type trackers struct {
+	mu  sync.Mutex
+	chs map[string]*amqp.Channel
+}
+
+func newTrackers() *trackers {
+	return &trackers{chs: map[string]*amqp.Channel{}}
+}
+
+// track follows the moves and wars of a game so its players can be paid
+// for the locations they hold. Players send their moves to the server,
+// which only publishes the ones their units can make, and in fog of war
+// only to the players who can see them. Defenders declare their wars to the
+// server too, which rolls the dice for them. Every subscription is made on
+// a channel of the game's own, closed once the game has ended.
+func (t *trackers) track(conn *amqp.Connection, ch *amqp.Channel, games *lobby.Lobby, turns *lobby.Turns, players *lobby.Registry, name string) error {
+	track, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("couldn't open a channel for %s: %v", name, err)
+	}
+	pub := pubsub.ChannelPublisher{Ch: ch}
+	// Moves, orders and wars are bound player by player as they join, see
+	// admit.
+	if err := pubsub.SubscribeJSONKeyedOn(
+		track,
+		routing.ExchangePerilDirect,
+		routing.GameKey(name, routing.MoveIntentsPrefix),
+		routing.GameKey(name, routing.MoveIntentsPrefix),
+		pubsub.QueueTypeTransient,
+		lobby.HandlerIntent(pub, games, turns, players, name, time.Now, logf),
+	); err != nil {
+		track.Close()
+		return err
+	}
+	if err := pubsub.SubscribeJSONOn(
+		track,
+		routing.ExchangePerilDirect,
+		routing.GameKey(name, routing.OrdersPrefix),
+		routing.GameKey(name, routing.OrdersPrefix),
+		pubsub.QueueTypeTransient,
+		lobby.HandlerOrders(games, turns, players, logf),
+	); err != nil {
+		track.Close()
+		return err
+	}
+	if err := pubsub.SubscribeJSONKeyedOn(
+		track,
+		routing.ExchangePerilDirect,
+		routing.GameKey(name, routing.WarDeclarationsPrefix),
+		routing.GameKey(name, routing.WarDeclarationsPrefix),
+		pubsub.QueueTypeTransient,
+		lobby.HandlerWar(pub, games, players, name, logf),
+	); err != nil {
+		track.Close()
+		return err
+	}
+	// Whether the game ends from the console or is won, it is paused one
+	// last time.
+	if err := pubsub.SubscribeJSONOn(
+		track,
+		routing.ExchangePerilDirect,
+		routing.GameKey(name, routing.PauseKey, "server"),
+		routing.GameKey(name, routing.PauseKey),
+		pubsub.QueueTypeTransient,
+		func(routing.PlayingState) pubsub.AckType {
+			if game, ok := games.Get(name); ok && game.Status == routing.GameStatusEnded {
+				logf("Stopped tracking the armies of %s", name)
+				t.mu.Lock()
+				delete(t.chs, name)
+				t.mu.Unlock()
+				go track.Close()
+			}
+			return pubsub.AckTypeAck
+		},
+	); err != nil {
+		track.Close()
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chs[name] = track
+	return nil
+}
+
+// admit binds the keys a player of a game sends their moves, orders and
+// wars to.
+// They go to peril_direct, where only the server binds them, so nobody
+// else ever sees what a player sends the server.
+func (t *trackers) admit(name, username string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	track, ok := t.chs[name]
+	if !ok {
+		// Missing and ended games take no moves, joining them fails.
+		return nil
+	}
+	for _, prefix := range []string{routing.MoveIntentsPrefix, routing.OrdersPrefix, routing.WarDeclarationsPrefix} {
+		if err := track.QueueBind(
+			routing.GameKey(name, prefix),
+			routing.GameKey(name, prefix, username),
+			routing.ExchangePerilDirect,
+			false,
+			nil,
+		); err != nil {
+			return fmt.Errorf("couldn't take the %s of %s in %s: %v", prefix, username, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// serveChat checks the messages players send and delivers the ones that
+// pass, from every game. Players send them to peril_direct, where only the
+// server binds, and can only send as the player their token was issued to.
+func serveChat(conn *amqp.Connection, ch *amqp.Channel, games *lobby.Lobby, players *lobby.Registry) error {
+	chat := lobby.NewChat()
+	pub := pubsub.ChannelPublisher{Ch: ch}
+	return pubsub.SubscribeJSON(
+		conn,
+		routing.ExchangePerilDirect,
+		routing.ChatSendKey,
+		routing.ChatSendKey,
+		pubsub.QueueTypeTransient,
+		func(msg routing.ChatMessage) pubsub.AckType {
+			if err := players.Authenticate(msg.Username, msg.Token); err != nil {
+				fmt.Printf("\nDiscarding a chat message from %s: %v\n> ", msg.Username, err)
+				return pubsub.AckTypeNackDiscard
+			}
+			if err := lobby.RelayChat(pub, games, chat, msg, time.Now()); err != nil {
+				fmt.Printf("\n%v\n> ", err)
+				return pubsub.AckTypeNackDiscard
+			}
+			return pubsub.AckTypeAck
+		},
+	)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// serveDiplomacy keeps the pacts of every game and sends them to its
+// players whenever they change. Only the player themselves can negotiate.
+func serveDiplomacy(conn *amqp.Connection, ch *amqp.Channel, games *lobby.Lobby, players *lobby.Registry) error {
+	return pubsub.ServeJSON(
+		conn,
+		routing.ExchangePerilDirect,
+		routing.DiplomacyRequestKey,
+		routing.DiplomacyRequestKey,
+		func(req routing.DiplomacyRequest) routing.DiplomacyResponse {
+			if err := players.Authenticate(req.Username, req.Token); err != nil {
+				return routing.DiplomacyResponse{Error: err.Error()}
+			}
+			state, err := games.Negotiate(req)
+			if err != nil {
+				return routing.DiplomacyResponse{Error: err.Error()}
+			}
+			if err := announceDiplomacy(ch, state); err != nil {
+				fmt.Printf("\n%v\n> ", err)
+			}
+			fmt.Printf("\n%s: %s %s %s\n> ", req.Game, req.Username, req.Action, req.With)
+			return routing.DiplomacyResponse{State: state}
+		},
+	)
+}
+
+// announceDiplomacy sends the pacts and teams of a game to its players.
+func announceDiplomacy(ch *amqp.Channel, state routing.DiplomacyState) error {
+	if err := pubsub.PublishJSON(
+		ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(state.Game, routing.DiplomacyKey),
+		state,
+	); err != nil {
+		return fmt.Errorf("couldn't announce the diplomacy of %s: %v", state.Game, err)
+	}
+	return nil
+}
+
+// setTeam puts a player on a team and tells the game, so teammates stop
+// fighting each other.
+func setTeam(ch *amqp.Channel, games *lobby.Lobby, name, username, team string) (routing.GameSummary, error) {
+	before, _ := games.Get(name)
+	game, err := games.SetTeam(name, username, team)
+	if err != nil {
+		return routing.GameSummary{}, err
+	}
+	if before.Teams[username] == game.Teams[username] {
+		return game, nil
+	}
+	state, err := games.Diplomacy(name)
+	if err != nil {
+		return routing.GameSummary{}, err
+	}
+	return game, announceDiplomacy(ch, state)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// serveTreasury charges players for the units they spawn. Only the player
+// themselves can spend their gold.
+func serveTreasury(conn *amqp.Connection, games *lobby.Lobby, players *lobby.Registry) error {
+	return pubsub.ServeJSON(
+		conn,
+		routing.ExchangePerilDirect,
+		routing.TreasurySpendKey,
+		routing.TreasurySpendKey,
+		func(req routing.SpendRequest) routing.SpendResponse {
+			if err := players.Authenticate(req.Username, req.Token); err != nil {
+				return routing.SpendResponse{Error: err.Error()}
+			}
+			t, err := games.Spend(req)
+			if err != nil {
+				if !errors.Is(err, lobby.ErrNotEnoughGold) {
+					fmt.Printf("\nRefused a spawn by %s in %s: %v\n> ", req.Username, req.Game, err)
+				}
+				return routing.SpendResponse{Error: err.Error()}
+			}
+			return routing.SpendResponse{Treasury: t}
+		},
+	)
+}
+
+// payIncome pays the players of games that are not in turn mode every
+// lobby.IncomeInterval. Games in turn mode are paid by runRounds.
+func payIncome(ch *amqp.Channel, games *lobby.Lobby, turns *lobby.Turns) {
+	ticker := time.NewTicker(lobby.IncomeInterval)
+	defer ticker.Stop()
+
+	pub := pubsub.ChannelPublisher{Ch: ch}
+	for now := range ticker.C {
+		if err := lobby.PayDue(pub, games, turns, now); err != nil {
+			fmt.Printf("\nCouldn't pay income: %v\n> ", err)
+		}
+	}
+}
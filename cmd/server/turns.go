@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const roundPollInterval = 250 * time.Millisecond
+
+// runRounds runs the rounds of a game until stopped is closed, so turning
+// turn mode off and on again never leaves two runners behind.
+func runRounds(ch *amqp.Channel, games *lobby.Lobby, turns *lobby.Turns, name string, stopped <-chan struct{}) {
+	ticker := time.NewTicker(roundPollInterval)
+	defer ticker.Stop()
+
+	pub := pubsub.ChannelPublisher{Ch: ch}
+	for {
+		var now time.Time
+		select {
+		case <-stopped:
+			return
+		case now = <-ticker.C:
+		}
+		select {
+		case <-stopped:
+			return
+		default:
+		}
+		more, err := turns.Advance(pub, games, name, now)
+		if err != nil {
+			fmt.Printf("\nCouldn't advance %s: %v\n> ", name, err)
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+func stopRounds(ch *amqp.Channel, turns *lobby.Turns, name string) error {
+	if err := turns.Disable(name); err != nil {
+		return err
+	}
+	return pubsub.PublishJSON(
+		ch,
+		routing.ExchangePerilDirect,
+		routing.GameKey(name, routing.RoundKey),
+		routing.RoundState{TurnBased: false},
+	)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/bot"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/sim"
+)
+
+func main() {
+	seed := flag.Int64("seed", 1, "random seed, the same seed replays the same game")
+	bots := flag.Int("bots", 4, "number of bots")
+	strategies := flag.String("strategy", strings.Join(bot.Strategies(), ","), "comma-separated strategies, assigned to the bots in turn")
+	steps := flag.Int("steps", 100, "number of steps to play")
+	tick := flag.Duration("tick", time.Second, "virtual time between two steps")
+	turnBased := flag.Bool("turns", false, "play in turn mode")
+	round := flag.Duration("round", 10*time.Second, "round duration in turn mode")
+	showLog := flag.Bool("log", false, "print every message published during the game")
+	mapFile := flag.String("map", "", "JSON or YAML map to play on, the classic map if empty")
+	unitsFile := flag.String("units", "", "JSON or YAML unit catalog to play with, the classic units if empty")
+	economy := gamelogic.ClassicEconomy()
+	flag.IntVar(&economy.StartingGold, "gold", economy.StartingGold, "gold the bots start with")
+	flag.IntVar(&economy.LocationIncome, "income", economy.LocationIncome, "gold the bots earn per location they hold every round")
+	teams := flag.String("teams", "", "comma-separated teams, assigned to the bots in turn, none if empty")
+	victory := gamelogic.Victory{}
+	flag.IntVar(&victory.Territories, "win-territories", 0, "territories a side must hold to win, 0 to not win by territories")
+	flag.IntVar(&victory.HoldRounds, "win-rounds", 1, "rounds in a row a side must hold -win-territories")
+	flag.BoolVar(&victory.Elimination, "win-elimination", false, "end the game when a single side has units left")
+	flag.IntVar(&victory.RoundLimit, "round-limit", 0, "end the game after this many rounds, won by the best score, 0 for no limit. Outside turn mode a round is a payday")
+	combat := flag.String("combat", gamelogic.CombatStrength, "how wars are fought: "+strings.Join(gamelogic.CombatRules(), " or "))
+	fog := flag.Bool("fog", false, "play in fog of war, bots only see enemy units near their own")
+	restart := flag.Int("restart", 0, "step every bot restarts at, resuming from its journal, 0 to never restart")
+	flag.Parse()
+
+	rules, err := gamelogic.LoadRules(*mapFile, *unitsFile)
+	if err == nil {
+		err = economy.Validate()
+	}
+	if err == nil {
+		_, err = gamelogic.NewCombatResolver(*combat)
+	}
+	if err == nil {
+		err = victory.Validate()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	rules.Economy = economy
+	rules.Combat = *combat
+	rules.Victory = victory
+	rules.Fog = *fog
+	teamNames := []string{}
+	if *teams != "" {
+		teamNames = strings.Split(*teams, ",")
+	}
+
+	result, err := sim.Run(sim.Config{
+		Seed:          *seed,
+		Bots:          *bots,
+		Strategies:    strings.Split(*strategies, ","),
+		Teams:         teamNames,
+		Steps:         *steps,
+		Tick:          *tick,
+		TurnBased:     *turnBased,
+		RoundDuration: *round,
+		Rules:         rules,
+		Restart:       *restart,
+	})
+	if result == nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *showLog {
+		for _, msg := range result.Messages {
+			body := string(msg.Body)
+			if msg.ContentType != "application/json" {
+				body = fmt.Sprintf("(%d bytes of %s)", len(msg.Body), msg.ContentType)
+			}
+			fmt.Printf("%5d %s %s %s %s\n", msg.Seq, msg.Time.Format(time.TimeOnly), msg.Exchange, msg.RoutingKey, body)
+		}
+	}
+
+	fmt.Printf("%d message(s), %d war(s), %d dead letter(s), %d refused command(s)\n",
+		len(result.Messages), result.Wars, len(result.DeadLetters), len(result.BotErrors))
+	if over := result.GameOver; over != nil {
+		fmt.Printf("Game over after %d step(s): %s won, %s\n", result.Steps, strings.Join(over.Winners, ", "), over.Reason)
+	}
+	for i, p := range result.Players {
+		units := []gamelogic.Unit{}
+		for _, unit := range p.Units {
+			units = append(units, unit)
+		}
+		fmt.Printf("%s: %d unit(s), attack %d, defense %d, %d gold\n", p.Username, len(units), rules.Catalog.Attack(units), rules.Catalog.Defense(units), result.Treasuries[i].Gold)
+	}
+
+	if err != nil {
+		fmt.Println("Invariants violated:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
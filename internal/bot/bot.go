@@ -0,0 +1,211 @@
+package bot
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const DefaultBudget = 30
+
+// Bot plays a GameState on its own. Its handlers keep World up to date
+// with the other players' armies so the strategy can see them. Game and
+// Publisher must be set before the bot plays.
+type Bot struct {
+	Username string
+	// Token is the bot's registration token, sent along with its moves,
+	// orders and wars.
+	Token     string
+	Game      string
+	GS        *gamelogic.GameState
+	World     *gamelogic.World
+	Publisher pubsub.Publisher
+	Now       func() time.Time
+
+	strategy  Strategy
+	rng       *rand.Rand
+	budget    int
+	reactions [][]string
+	mu        *sync.Mutex
+}
+
+func New(username string, strategy Strategy, budget int, seed int64) *Bot {
+	b := &Bot{
+		Username: username,
+		GS:       gamelogic.NewGameState(username),
+		World:    gamelogic.NewWorld(),
+		Now:      time.Now,
+		strategy: strategy,
+		rng:      rand.New(rand.NewSource(seed)),
+		budget:   budget,
+		mu:       &sync.Mutex{},
+	}
+	b.GS.Subscribe(gamelogic.SubscriberFunc(b.notify))
+	return b
+}
+
+// SetRules switches the bot, and its view of the other armies, to the
+// rules the game is played by.
+func (b *Bot) SetRules(r gamelogic.Rules) {
+	b.GS.SetRules(r)
+	b.World.SetRules(r)
+}
+
+// Strategy is the name of the strategy the bot plays by.
+func (b *Bot) Strategy() string {
+	return b.strategy.Name()
+}
+
+// Budget is how many points the bot has left to spend on units.
+func (b *Bot) Budget() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.budget
+}
+
+// notify queues the strategy's answer to every war the bot fought in, so it
+// is played before anything else on the next step.
+func (b *Bot) notify(e gamelogic.Event) {
+	war, ok := e.(gamelogic.WarResolved)
+	if !ok || war.Outcome == gamelogic.WarOutcomeNoUnits {
+		return
+	}
+	if war.Attacker != b.Username && war.Defender != b.Username {
+		return
+	}
+	v := b.view()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v.Budget = b.spendable()
+	if words := b.strategy.React(v, war, b.rng); words != nil {
+		b.reactions = append(b.reactions, words)
+	}
+}
+
+// Step plays one command. It reports the move to publish when the bot moved
+// or queued units, and does nothing while the game is paused.
+func (b *Bot) Step() (gamelogic.ArmyMove, bool, error) {
+	if b.GS.IsPaused() {
+		return gamelogic.ArmyMove{}, false, nil
+	}
+	v := b.view()
+
+	b.mu.Lock()
+	v.Budget = b.spendable()
+	var words []string
+	if len(b.reactions) > 0 {
+		words, b.reactions = b.reactions[0], b.reactions[1:]
+	} else {
+		words = b.strategy.Turn(v, b.rng)
+	}
+	b.mu.Unlock()
+
+	if len(words) == 0 {
+		return gamelogic.ArmyMove{}, false, nil
+	}
+	switch words[0] {
+	case "spawn":
+		return gamelogic.ArmyMove{}, false, b.spawn(words)
+	case "move":
+		mv, err := b.GS.CommandMove(words)
+		if err != nil {
+			return gamelogic.ArmyMove{}, false, err
+		}
+		return mv, true, nil
+	}
+	return gamelogic.ArmyMove{}, false, fmt.Errorf("strategy %s returned unknown command: %s", b.strategy.Name(), words[0])
+}
+
+// Play runs one step and publishes its result. In turn mode the bot queues
+// at most one move per round and submits its orders right away.
+func (b *Bot) Play() error {
+	if b.GS.IsPaused() {
+		return nil
+	}
+	turn := b.GS.Turn()
+	if turn.TurnBased && (!turn.Open || turn.Submitted) {
+		return nil
+	}
+
+	mv, moved, err := b.Step()
+	if err != nil {
+		return err
+	}
+
+	if turn.TurnBased {
+		orders, err := b.GS.CommandSubmit()
+		if err != nil {
+			return err
+		}
+		orders.Game = b.Game
+		orders.Token = b.Token
+		return b.Publisher.PublishJSON(
+			routing.ExchangePerilDirect,
+			routing.GameKey(b.Game, routing.OrdersPrefix, b.Username),
+			orders,
+		)
+	}
+	if !moved {
+		return nil
+	}
+	intent := b.GS.Intent(mv)
+	intent.Token = b.Token
+	return b.Publisher.PublishJSON(
+		routing.ExchangePerilDirect,
+		routing.GameKey(b.Game, routing.MoveIntentsPrefix, b.Username),
+		intent,
+	)
+}
+
+// spendable is what the bot can spend right now: its budget, or its gold
+// when the game has an economy and that is less. b.mu must be held.
+func (b *Bot) spendable() int {
+	if t, banked := b.GS.Treasury(); banked {
+		return min(b.budget, t.Gold)
+	}
+	return b.budget
+}
+
+func (b *Bot) spawn(words []string) error {
+	if len(words) < 3 {
+		return fmt.Errorf("strategy %s returned an invalid spawn", b.strategy.Name())
+	}
+	cost := b.GS.Catalog().Cost(gamelogic.UnitRank(words[2]))
+
+	b.mu.Lock()
+	if cost > b.budget {
+		b.mu.Unlock()
+		return fmt.Errorf("can't afford a(n) %s, %d point(s) left", words[2], b.budget)
+	}
+	b.budget -= cost
+	b.mu.Unlock()
+
+	if err := b.GS.CommandSpawn(words); err != nil {
+		b.mu.Lock()
+		b.budget += cost
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (b *Bot) view() View {
+	others := []gamelogic.Player{}
+	for _, p := range b.World.Players() {
+		if p.Username != b.Username {
+			others = append(others, p)
+		}
+	}
+	return View{
+		Map:     b.GS.Map(),
+		Catalog: b.GS.Catalog(),
+		Self:    b.GS.GetPlayerSnap(),
+		Others:  others,
+	}
+}
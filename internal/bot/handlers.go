@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func (b *Bot) HandlerPause() func(routing.PlayingState) pubsub.AckType {
+	return func(ps routing.PlayingState) pubsub.AckType {
+		b.GS.HandlePause(ps)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (b *Bot) HandlerRound() func(routing.RoundState) pubsub.AckType {
+	return func(rs routing.RoundState) pubsub.AckType {
+		b.GS.HandleRound(rs)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (b *Bot) HandlerTreasury() func(routing.Treasury) pubsub.AckType {
+	return func(t routing.Treasury) pubsub.AckType {
+		b.GS.HandleTreasury(t)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (b *Bot) HandlerDiplomacy() func(routing.DiplomacyState) pubsub.AckType {
+	return func(state routing.DiplomacyState) pubsub.AckType {
+		b.GS.HandleDiplomacy(state)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (b *Bot) HandlerGameOver() func(routing.GameOver) pubsub.AckType {
+	return func(over routing.GameOver) pubsub.AckType {
+		b.GS.HandleGameOver(over)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (b *Bot) HandlerMove() func(gamelogic.ArmyMove) pubsub.AckType {
+	return func(mv gamelogic.ArmyMove) pubsub.AckType {
+		if mv.Player.Username != b.Username {
+			// A gap the world couldn't resync is caught up on by the next
+			// snapshot.
+			mv, _ = b.World.ApplyMove(mv)
+		}
+		switch b.GS.HandleMove(mv) {
+		case gamelogic.MoveOutcomeMakeWar:
+			// The server fights the war with the armies it knows.
+			decl := b.GS.DeclareWar(mv.Player)
+			decl.Token = b.Token
+			if err := b.Publisher.PublishJSON(
+				routing.ExchangePerilDirect,
+				routing.GameKey(b.Game, routing.WarDeclarationsPrefix, b.Username),
+				decl,
+			); err != nil {
+				return pubsub.AckTypeNackRequeue
+			}
+			return pubsub.AckTypeAck
+		case gamelogic.MoveOutComeSafe, gamelogic.MoveOutcomeSamePlayer:
+			return pubsub.AckTypeAck
+		}
+		return pubsub.AckTypeNackDiscard
+	}
+}
+
+// HandlerSighting handles the moves the server relays in fog of war. A
+// scouting sighting is not a move, it only shows the bot enemy units.
+func (b *Bot) HandlerSighting() func(gamelogic.Sighting) pubsub.AckType {
+	handleMove := b.HandlerMove()
+	return func(s gamelogic.Sighting) pubsub.AckType {
+		if s.Scouted {
+			b.World.ApplyMove(s.Move)
+			return pubsub.AckTypeAck
+		}
+		return handleMove(s.Move)
+	}
+}
+
+// HandlerRejection takes back a move the server refused, so the bot plans
+// its next ones from where its units really are.
+func (b *Bot) HandlerRejection() func(gamelogic.Rejection) pubsub.AckType {
+	return func(r gamelogic.Rejection) pubsub.AckType {
+		b.GS.HandleRejection(r)
+		return pubsub.AckTypeAck
+	}
+}
+
+func (b *Bot) HandlerWar() func(gamelogic.RecognitionOfWar) pubsub.AckType {
+	return func(rw gamelogic.RecognitionOfWar) pubsub.AckType {
+		outcome, results := b.GS.HandleWar(rw)
+		b.World.ApplyWar(rw)
+		switch outcome {
+		case gamelogic.WarOutcomeNotInvolved:
+			return pubsub.AckTypeAck
+
+		case gamelogic.WarOutcomeNoUnits:
+			return pubsub.AckTypeNackDiscard
+
+		case gamelogic.WarOutcomeOpponentWon, gamelogic.WarOutcomeYouWon, gamelogic.WarOutcomeDraw:
+			// Every side hears of the war, the attacker logs it.
+			if rw.Attacker.Username != b.Username {
+				return pubsub.AckTypeAck
+			}
+			for _, result := range results {
+				if err := b.Publisher.PublishGob(
+					routing.ExchangePerilTopic,
+					routing.GameKey(b.Game, routing.GameLogSlug, b.Username),
+					routing.GameLog{
+						CurrentTime: b.Now(),
+						Message:     result.Battle.Summary(),
+						Username:    b.Username,
+					},
+				); err != nil {
+					return pubsub.AckTypeNackRequeue
+				}
+			}
+			return pubsub.AckTypeAck
+		}
+		return pubsub.AckTypeNackDiscard
+	}
+}
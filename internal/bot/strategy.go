@@ -0,0 +1,252 @@
+package bot
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// View is what a strategy knows when it picks a command: the board and
+// units of the game, the bot's own army, every other army it has seen move,
+// and what it can still spend.
+type View struct {
+	Map     *gamelogic.Map
+	Catalog *gamelogic.Catalog
+	Self    gamelogic.Player
+	Others  []gamelogic.Player
+	Budget  int
+}
+
+// Strategy decides what a bot does. Commands are returned as words, exactly
+// as a player would type them, and nil means the bot passes.
+type Strategy interface {
+	Name() string
+	Turn(v View, rng *rand.Rand) []string
+	React(v View, war gamelogic.WarResolved, rng *rand.Rand) []string
+}
+
+func Strategies() []string {
+	return []string{"defensive", "greedy", "random"}
+}
+
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case "random":
+		return Random{}, nil
+	case "greedy":
+		return Greedy{}, nil
+	case "defensive":
+		return Defensive{}, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q, expected one of %s", name, strings.Join(Strategies(), ", "))
+}
+
+// Random spawns and moves without looking at the board.
+type Random struct{}
+
+func (Random) Name() string { return "random" }
+
+func (Random) Turn(v View, rng *rand.Rand) []string {
+	ranks := v.affordable()
+	if len(ranks) > 0 && (len(v.Self.Units) == 0 || rng.Intn(2) == 0) {
+		locations := v.Map.Locations()
+		loc := locations[rng.Intn(len(locations))]
+		return spawn(loc, ranks[rng.Intn(len(ranks))])
+	}
+
+	units := sortedUnits(v.Self)
+	if len(units) == 0 {
+		return nil
+	}
+	unit := units[rng.Intn(len(units))]
+	neighbors := v.Map.Neighbors(unit.Location)
+	return move(neighbors[rng.Intn(len(neighbors))], []gamelogic.Unit{unit})
+}
+
+func (Random) React(View, gamelogic.WarResolved, *rand.Rand) []string {
+	return nil
+}
+
+// Greedy attacks the richest bordering enemy stack it can beat, builds up its
+// strongest stack otherwise, and tries to retake locations it lost.
+type Greedy struct{}
+
+func (Greedy) Name() string { return "greedy" }
+
+func (Greedy) Turn(v View, rng *rand.Rand) []string {
+	own := stacks(v.Self)
+	var best []gamelogic.Unit
+	var target gamelogic.Location
+	bestGain := 0
+	for _, from := range v.Map.Locations() {
+		units := own[from]
+		if len(units) == 0 {
+			continue
+		}
+		for _, to := range v.Map.Neighbors(from) {
+			enemy := v.enemyPower(to, v.Catalog.Defense)
+			power := v.Catalog.Attack(append(slices.Clone(units), own[to]...))
+			if enemy > bestGain && enemy < power {
+				best, target, bestGain = units, to, enemy
+			}
+		}
+	}
+	if best != nil {
+		return move(target, best)
+	}
+
+	ranks := v.affordable()
+	if len(ranks) == 0 {
+		return nil
+	}
+	home, ok := v.strongest(own)
+	if !ok {
+		home = v.safest(v.Map.Locations(), rng)
+	}
+	return spawn(home, ranks[len(ranks)-1])
+}
+
+// React retakes the first location the war didn't win.
+func (Greedy) React(v View, war gamelogic.WarResolved, rng *rand.Rand) []string {
+	ranks := v.affordable()
+	if len(ranks) == 0 {
+		return nil
+	}
+	for _, result := range war.Results {
+		if result.Outcome == gamelogic.WarOutcomeOpponentWon || result.Outcome == gamelogic.WarOutcomeDraw {
+			return spawn(result.Battle.Location, ranks[len(ranks)-1])
+		}
+	}
+	return nil
+}
+
+// Defensive never attacks. It keeps its army away from stronger stacks and
+// reinforces wherever it is attacked.
+type Defensive struct{}
+
+func (Defensive) Name() string { return "defensive" }
+
+func (Defensive) Turn(v View, rng *rand.Rand) []string {
+	own := stacks(v.Self)
+	for _, loc := range v.Map.Locations() {
+		units := own[loc]
+		if len(units) == 0 || v.enemyPower(loc, v.Catalog.Attack) <= v.Catalog.Defense(units) {
+			continue
+		}
+		to := v.safest(v.Map.Neighbors(loc), rng)
+		if v.enemyPower(to, v.Catalog.Attack) >= v.enemyPower(loc, v.Catalog.Attack) {
+			continue
+		}
+		return move(to, units)
+	}
+
+	ranks := v.affordable()
+	if len(ranks) == 0 {
+		return nil
+	}
+	home, ok := v.strongest(own)
+	if !ok || v.enemyPower(home, v.Catalog.Attack) > 0 {
+		home = v.safest(v.Map.Locations(), rng)
+	}
+	return spawn(home, ranks[len(ranks)-1])
+}
+
+func (Defensive) React(v View, war gamelogic.WarResolved, rng *rand.Rand) []string {
+	if war.Defender != v.Self.Username || len(war.Results) == 0 {
+		return nil
+	}
+	ranks := v.affordable()
+	if len(ranks) == 0 {
+		return nil
+	}
+	return spawn(war.Results[0].Battle.Location, ranks[len(ranks)-1])
+}
+
+// enemyPower is the attack or defense of the strongest single enemy stack
+// in loc, since wars are always fought between two players.
+func (v View) enemyPower(loc gamelogic.Location, strength func([]gamelogic.Unit) int) int {
+	power := 0
+	for _, p := range v.Others {
+		power = max(power, strength(stacks(p)[loc]))
+	}
+	return power
+}
+
+// safest returns a random location among those of locations with the
+// weakest enemy presence.
+func (v View) safest(locations []gamelogic.Location, rng *rand.Rand) gamelogic.Location {
+	candidates := []gamelogic.Location{}
+	lowest := -1
+	for _, loc := range locations {
+		power := v.enemyPower(loc, v.Catalog.Attack)
+		switch {
+		case lowest == -1 || power < lowest:
+			lowest = power
+			candidates = []gamelogic.Location{loc}
+		case power == lowest:
+			candidates = append(candidates, loc)
+		}
+	}
+	return candidates[rng.Intn(len(candidates))]
+}
+
+func stacks(p gamelogic.Player) map[gamelogic.Location][]gamelogic.Unit {
+	s := map[gamelogic.Location][]gamelogic.Unit{}
+	for _, unit := range sortedUnits(p) {
+		s[unit.Location] = append(s[unit.Location], unit)
+	}
+	return s
+}
+
+func (v View) strongest(s map[gamelogic.Location][]gamelogic.Unit) (gamelogic.Location, bool) {
+	var best gamelogic.Location
+	bestPower := 0
+	for _, loc := range v.Map.Locations() {
+		if power := v.Catalog.Defense(s[loc]); power > bestPower {
+			best, bestPower = loc, power
+		}
+	}
+	return best, bestPower > 0
+}
+
+// affordable returns the ranks that fit in the budget, cheapest first.
+func (v View) affordable() []gamelogic.UnitRank {
+	ranks := []gamelogic.UnitRank{}
+	for _, rank := range v.Catalog.Ranks() {
+		if v.Catalog.Cost(rank) <= v.Budget {
+			ranks = append(ranks, rank)
+		}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		return v.Catalog.Cost(ranks[i]) < v.Catalog.Cost(ranks[j])
+	})
+	return ranks
+}
+
+func sortedUnits(p gamelogic.Player) []gamelogic.Unit {
+	units := []gamelogic.Unit{}
+	for _, unit := range p.Units {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	return units
+}
+
+func spawn(loc gamelogic.Location, rank gamelogic.UnitRank) []string {
+	return []string{"spawn", string(loc), string(rank)}
+}
+
+func move(to gamelogic.Location, units []gamelogic.Unit) []string {
+	words := []string{"move", string(to)}
+	for _, unit := range units {
+		words = append(words, strconv.Itoa(unit.ID))
+	}
+	return words
+}
@@ -0,0 +1,219 @@
+package gamelogic
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Special rules a unit type can have.
+const (
+	// SpecialAmbush units defend with their attack when it is higher than
+	// their defense.
+	SpecialAmbush = "ambush"
+	// SpecialSupport units add 1 to the attack and defense of every other
+	// unit they fight alongside.
+	SpecialSupport = "support"
+)
+
+func SpecialRules() []string {
+	return []string{SpecialAmbush, SpecialSupport}
+}
+
+// Catalog lists the unit types of a game. Every participant must use the
+// same catalog, or they won't agree on who wins a war.
+type Catalog struct {
+	Name  string
+	Units []UnitType
+
+	index map[UnitRank]UnitType
+}
+
+type UnitType struct {
+	Rank     UnitRank
+	Attack   int
+	Defense  int
+	Movement int
+	Cost     int
+	Special  []string `json:",omitempty"`
+}
+
+func (t UnitType) Has(special string) bool {
+	return slices.Contains(t.Special, special)
+}
+
+//go:embed units/classic.json
+var classicCatalogData []byte
+
+var classicCatalog = mustParseCatalog(classicCatalogData)
+
+// ClassicCatalog is infantry, cavalry and artillery, worth 1, 5 and 10.
+func ClassicCatalog() *Catalog {
+	return classicCatalog
+}
+
+func mustParseCatalog(data []byte) *Catalog {
+	c, err := ParseCatalog(data)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LoadCatalog reads a catalog from a .json, .yaml or .yml file and
+// validates it.
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read unit catalog: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		c := &Catalog{}
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("couldn't parse unit catalog %s: %v", path, err)
+		}
+		return c, c.Validate()
+	case ".json":
+		return ParseCatalog(data)
+	}
+	return nil, fmt.Errorf("unsupported unit catalog file %s, expected .json, .yaml or .yml", path)
+}
+
+func ParseCatalog(data []byte) (*Catalog, error) {
+	c := &Catalog{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("couldn't parse unit catalog: %v", err)
+	}
+	return c, c.Validate()
+}
+
+// Validate checks every unit type and indexes them by rank. Catalogs must
+// be validated before they are used.
+func (c *Catalog) Validate() error {
+	if c.Name == "" {
+		return errors.New("unit catalog has no name")
+	}
+	if len(c.Units) == 0 {
+		return fmt.Errorf("unit catalog %s has no units", c.Name)
+	}
+	c.index = map[UnitRank]UnitType{}
+	for _, t := range c.Units {
+		if t.Rank == "" || strings.ContainsAny(string(t.Rank), " \t\n") {
+			return fmt.Errorf("unit catalog %s: rank %q must be a single word", c.Name, t.Rank)
+		}
+		if _, ok := c.index[t.Rank]; ok {
+			return fmt.Errorf("unit catalog %s: %s is defined twice", c.Name, t.Rank)
+		}
+		if t.Attack < 0 || t.Defense < 0 || t.Cost < 0 {
+			return fmt.Errorf("unit catalog %s: %s can't have a negative attack, defense or cost", c.Name, t.Rank)
+		}
+		if t.Movement < 1 {
+			return fmt.Errorf("unit catalog %s: %s must move at least 1 territory", c.Name, t.Rank)
+		}
+		for _, special := range t.Special {
+			if !slices.Contains(SpecialRules(), special) {
+				return fmt.Errorf("unit catalog %s: %s has unknown special rule %q, expected one of %s",
+					c.Name, t.Rank, special, strings.Join(SpecialRules(), ", "))
+			}
+		}
+		c.index[t.Rank] = t
+	}
+	return nil
+}
+
+func (c *Catalog) Lookup(rank UnitRank) (UnitType, bool) {
+	t, ok := c.index[rank]
+	return t, ok
+}
+
+// Ranks returns every rank in alphabetical order.
+func (c *Catalog) Ranks() []UnitRank {
+	ranks := []UnitRank{}
+	for rank := range c.index {
+		ranks = append(ranks, rank)
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		return ranks[i] < ranks[j]
+	})
+	return ranks
+}
+
+// Cost returns what a unit of the given rank costs, 0 for unknown ranks.
+func (c *Catalog) Cost(rank UnitRank) int {
+	return c.index[rank].Cost
+}
+
+// Attack is the strength of units fighting together as the attacker.
+// Units of a rank the catalog doesn't know count for nothing.
+func (c *Catalog) Attack(units []Unit) int {
+	return c.strength(units, attackStat)
+}
+
+// Defense is the strength of units fighting together as the defender.
+func (c *Catalog) Defense(units []Unit) int {
+	return c.strength(units, defenseStat)
+}
+
+func attackStat(t UnitType) int {
+	return t.Attack
+}
+
+func defenseStat(t UnitType) int {
+	if t.Has(SpecialAmbush) {
+		return max(t.Attack, t.Defense)
+	}
+	return t.Defense
+}
+
+// stackStrengths is what every unit of a stack is worth on its own,
+// including the support it gets from the rest of the stack.
+func (c *Catalog) stackStrengths(stack []Unit, stat func(UnitType) int) []int {
+	supporters := 0
+	for _, unit := range stack {
+		if c.index[unit.Rank].Has(SpecialSupport) {
+			supporters++
+		}
+	}
+	strengths := []int{}
+	for _, unit := range stack {
+		t, ok := c.index[unit.Rank]
+		switch {
+		case !ok:
+			strengths = append(strengths, 0)
+		case t.Has(SpecialSupport):
+			strengths = append(strengths, stat(t)+supporters-1)
+		default:
+			strengths = append(strengths, stat(t)+supporters)
+		}
+	}
+	return strengths
+}
+
+func (c *Catalog) strength(units []Unit, stat func(UnitType) int) int {
+	total, known, supporters := 0, 0, 0
+	for _, unit := range units {
+		t, ok := c.index[unit.Rank]
+		if !ok {
+			continue
+		}
+		total += stat(t)
+		known++
+		if t.Has(SpecialSupport) {
+			supporters++
+		}
+	}
+	if supporters == 0 {
+		return total
+	}
+	// Every supporter boosts every other unit of the stack.
+	return total + supporters*(known-1)
+}
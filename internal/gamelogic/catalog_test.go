@@ -0,0 +1,114 @@
+package gamelogic
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		data      string
+		wantErr   bool
+		wantRanks []UnitRank
+	}{
+		{
+			name:      "json",
+			file:      "fantasy.json",
+			data:      `{"name": "fantasy", "units": [{"rank": "orc", "attack": 3, "defense": 1, "movement": 1, "cost": 2, "special": ["ambush"]}, {"rank": "dragon", "attack": 20, "defense": 20, "movement": 3, "cost": 25}]}`,
+			wantRanks: []UnitRank{"dragon", "orc"},
+		},
+		{
+			name:      "yaml",
+			file:      "fantasy.yaml",
+			data:      "name: fantasy\nunits:\n  - rank: orc\n    attack: 3\n    defense: 1\n    movement: 1\n    cost: 2\n  - rank: healer\n    movement: 1\n    special: [support]\n",
+			wantRanks: []UnitRank{"healer", "orc"},
+		},
+		{
+			name:    "unsupported file",
+			file:    "fantasy.txt",
+			data:    "orc",
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			file:    "fantasy.json",
+			data:    `{"name": "fantasy",`,
+			wantErr: true,
+		},
+		{
+			name:    "no name",
+			file:    "fantasy.json",
+			data:    `{"units": [{"rank": "orc", "movement": 1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "no units",
+			file:    "fantasy.json",
+			data:    `{"name": "fantasy"}`,
+			wantErr: true,
+		},
+		{
+			name:    "rank of several words",
+			file:    "fantasy.json",
+			data:    `{"name": "fantasy", "units": [{"rank": "orc chief", "movement": 1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "rank defined twice",
+			file:    "fantasy.json",
+			data:    `{"name": "fantasy", "units": [{"rank": "orc", "movement": 1}, {"rank": "orc", "movement": 2}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative cost",
+			file:    "fantasy.json",
+			data:    `{"name": "fantasy", "units": [{"rank": "orc", "movement": 1, "cost": -1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "no movement",
+			file:    "fantasy.json",
+			data:    `{"name": "fantasy", "units": [{"rank": "orc"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown special rule",
+			file:    "fantasy.json",
+			data:    `{"name": "fantasy", "units": [{"rank": "orc", "movement": 1, "special": ["flying"]}]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := LoadCatalog(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadCatalog() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := c.Ranks(); !slices.Equal(got, tt.wantRanks) {
+				t.Errorf("Ranks() = %v, want %v", got, tt.wantRanks)
+			}
+			for _, u := range c.Units {
+				if got, ok := c.Lookup(u.Rank); !ok || !slices.Equal(got.Special, u.Special) || got.Cost != u.Cost {
+					t.Errorf("Lookup(%s) = %+v, %v, want %+v", u.Rank, got, ok, u)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCatalogMissingFile(t *testing.T) {
+	if _, err := LoadCatalog(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loaded a unit catalog that doesn't exist")
+	}
+}
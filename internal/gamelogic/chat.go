@@ -0,0 +1,62 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// MaxChatLength is the longest message the server delivers.
+const MaxChatLength = 280
+
+// HandleChat shows a chat message delivered by the server.
+func (gs *GameState) HandleChat(msg routing.ChatMessage) {
+	gs.emit(ChatReceived{Message: msg, Player: gs.GetUsername()})
+}
+
+// CommandChat writes a message for a say, whisper or team command. The
+// server checks it before delivering it.
+func (gs *GameState) CommandChat(words []string) (routing.ChatMessage, error) {
+	msg := routing.ChatMessage{
+		Username: gs.GetUsername(),
+		SentAt:   time.Now(),
+	}
+	switch words[0] {
+	case "say":
+		if len(words) < 2 {
+			return routing.ChatMessage{}, errors.New("usage: say <message>")
+		}
+		msg.Channel = routing.ChatGlobal
+		msg.Text = strings.Join(words[1:], " ")
+	case "whisper":
+		if len(words) < 3 {
+			return routing.ChatMessage{}, errors.New("usage: whisper <player> <message>")
+		}
+		if words[1] == msg.Username {
+			return routing.ChatMessage{}, errors.New("error: you can't whisper to yourself")
+		}
+		msg.Channel = routing.ChatDirect
+		msg.To = words[1]
+		msg.Text = strings.Join(words[2:], " ")
+	case "team":
+		if len(words) < 2 {
+			return routing.ChatMessage{}, errors.New("usage: team <message>")
+		}
+		msg.Team = gs.Team()
+		if msg.Team == "" {
+			return routing.ChatMessage{}, errors.New("error: you are not on a team")
+		}
+		msg.Channel = routing.ChatTeam
+		msg.Text = strings.Join(words[1:], " ")
+	default:
+		return routing.ChatMessage{}, fmt.Errorf("error: unknown chat command %s", words[0])
+	}
+	if utf8.RuneCountInString(msg.Text) > MaxChatLength {
+		return routing.ChatMessage{}, fmt.Errorf("error: messages can be at most %d characters long", MaxChatLength)
+	}
+	return msg, nil
+}
@@ -0,0 +1,172 @@
+package gamelogic
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Combat rules a game can be played with.
+const (
+	// CombatStrength compares the strength of both stacks. The weaker one
+	// is wiped out, and both are on a draw.
+	CombatStrength = "strength"
+	// CombatDice fights in rounds of dice like Risk, and every lost roll
+	// costs a unit.
+	CombatDice = "dice"
+)
+
+func CombatRules() []string {
+	return []string{CombatDice, CombatStrength}
+}
+
+// CombatResolver decides the casualties of a battle. Everyone resolving a
+// war must reach the same result, so a resolver may only use the battle,
+// the catalog and rng, which is seeded from the war message.
+type CombatResolver interface {
+	Name() string
+	Resolve(b Battle, c *Catalog, rng *rand.Rand) Battle
+}
+
+func NewCombatResolver(name string) (CombatResolver, error) {
+	switch name {
+	case CombatStrength, "":
+		return StrengthResolver{}, nil
+	case CombatDice:
+		return DiceResolver{}, nil
+	}
+	return nil, fmt.Errorf("unknown combat rule %q, expected one of %s", name, strings.Join(CombatRules(), ", "))
+}
+
+// StrengthResolver is how wars have always been fought.
+type StrengthResolver struct{}
+
+func (StrengthResolver) Name() string { return CombatStrength }
+
+func (StrengthResolver) Resolve(b Battle, c *Catalog, rng *rand.Rand) Battle {
+	b.clearLosses()
+	switch {
+	case b.AttackerPower > b.DefenderPower:
+		b.Winner, b.Loser = b.Attacker, b.Defender
+		b.loseDefense()
+	case b.DefenderPower > b.AttackerPower:
+		b.Winner, b.Loser = b.Defender, b.Attacker
+		b.AttackerLosses = b.AttackerUnits
+	default:
+		b.Winner, b.Loser, b.Draw = b.Attacker, b.Defender, true
+		b.AttackerLosses = b.AttackerUnits
+		b.loseDefense()
+	}
+	return b
+}
+
+// DiceResolver fights until one stack is destroyed. Every round the three
+// strongest attackers and two strongest defenders each roll a die plus
+// their own strength. The best rolls of both sides are compared in pairs,
+// ties going to the defender, and the loser of each pair loses its weakest
+// unit.
+type DiceResolver struct{}
+
+func (DiceResolver) Name() string { return CombatDice }
+
+// CombatRound is one round of dice. Rolls include the strength of the unit
+// that rolled them.
+type CombatRound struct {
+	AttackerRolls  []int
+	DefenderRolls  []int
+	AttackerLosses []Unit
+	DefenderLosses []Unit
+}
+
+const (
+	attackerDice = 3
+	defenderDice = 2
+	dieFaces     = 6
+)
+
+// fighter is a unit in a battle, with the player it belongs to since
+// allies share a stack and unit IDs are only unique per player.
+type fighter struct {
+	owner    string
+	unit     Unit
+	strength int
+}
+
+func fighters(owner string, units []Unit) []fighter {
+	stack := []fighter{}
+	for _, unit := range units {
+		stack = append(stack, fighter{owner: owner, unit: unit})
+	}
+	return stack
+}
+
+func (DiceResolver) Resolve(b Battle, c *Catalog, rng *rand.Rand) Battle {
+	attackers := fighters(b.Attacker, b.AttackerUnits)
+	defenders := fighters(b.Defender, b.DefenderUnits)
+	for _, ally := range b.Allies {
+		defenders = append(defenders, fighters(ally.Username, ally.Units)...)
+	}
+	b.clearLosses()
+	for len(attackers) > 0 && len(defenders) > 0 {
+		round := CombatRound{AttackerLosses: []Unit{}, DefenderLosses: []Unit{}}
+		c.strongestFirst(attackers, attackStat)
+		c.strongestFirst(defenders, defenseStat)
+		round.AttackerRolls = roll(attackers, attackerDice, rng)
+		round.DefenderRolls = roll(defenders, defenderDice, rng)
+
+		for i := range min(len(round.AttackerRolls), len(round.DefenderRolls)) {
+			if round.AttackerRolls[i] > round.DefenderRolls[i] {
+				casualty := defenders[len(defenders)-1]
+				defenders = defenders[:len(defenders)-1]
+				round.DefenderLosses = append(round.DefenderLosses, casualty.unit)
+				b.lose(casualty.owner, casualty.unit)
+			} else {
+				casualty := attackers[len(attackers)-1]
+				attackers = attackers[:len(attackers)-1]
+				round.AttackerLosses = append(round.AttackerLosses, casualty.unit)
+				b.lose(casualty.owner, casualty.unit)
+			}
+		}
+		b.Rounds = append(b.Rounds, round)
+	}
+	if len(defenders) == 0 && len(attackers) > 0 {
+		b.Winner, b.Loser = b.Attacker, b.Defender
+	} else {
+		b.Winner, b.Loser = b.Defender, b.Attacker
+	}
+	return b
+}
+
+// strongestFirst sorts a stack by the strength of its units, ties broken by
+// owner and ID so that everyone sorts it the same way.
+func (c *Catalog) strongestFirst(stack []fighter, stat func(UnitType) int) {
+	units := []Unit{}
+	for _, f := range stack {
+		units = append(units, f.unit)
+	}
+	for i, strength := range c.stackStrengths(units, stat) {
+		stack[i].strength = strength
+	}
+	sort.Slice(stack, func(i, j int) bool {
+		a, b := stack[i], stack[j]
+		if a.strength != b.strength {
+			return a.strength > b.strength
+		}
+		if a.owner != b.owner {
+			return a.owner < b.owner
+		}
+		return a.unit.ID < b.unit.ID
+	})
+}
+
+// roll rolls for the first n fighters of a sorted stack and returns the
+// rolls from best to worst.
+func roll(stack []fighter, n int, rng *rand.Rand) []int {
+	rolls := []int{}
+	for _, f := range stack[:min(n, len(stack))] {
+		rolls = append(rolls, rng.Intn(dieFaces)+1+f.strength)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(rolls)))
+	return rolls
+}
@@ -0,0 +1,84 @@
+package gamelogic
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func testBattle() Battle {
+	return Battle{
+		Location: "europe",
+		Attacker: "alice",
+		Defender: "bob",
+		AttackerUnits: []Unit{
+			{ID: 1, Rank: RankInfantry, Location: "europe"},
+			{ID: 2, Rank: RankCavalry, Location: "europe"},
+			{ID: 3, Rank: RankArtillery, Location: "europe"},
+		},
+		DefenderUnits: []Unit{
+			{ID: 1, Rank: RankInfantry, Location: "europe"},
+			{ID: 2, Rank: RankInfantry, Location: "europe"},
+		},
+		Allies: []Ally{
+			{Username: "carol", Units: []Unit{{ID: 1, Rank: RankCavalry, Location: "europe"}}},
+		},
+	}
+}
+
+func TestDiceResolverSameSeed(t *testing.T) {
+	for _, seed := range []int64{0, 1, 42, -7, 1 << 40} {
+		first := DiceResolver{}.Resolve(testBattle(), ClassicCatalog(), rand.New(rand.NewSource(seed)))
+		second := DiceResolver{}.Resolve(testBattle(), ClassicCatalog(), rand.New(rand.NewSource(seed)))
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("seed %d: resolved the same battle twice differently:\n%+v\n%+v", seed, first, second)
+		}
+		if len(first.Rounds) == 0 || first.Winner == "" {
+			t.Errorf("seed %d: battle was not fought: %+v", seed, first)
+		}
+	}
+}
+
+func TestHandleWarEverySideLosesItsOwnUnits(t *testing.T) {
+	rules := ClassicRules()
+	rules.Combat = CombatDice
+	b := testBattle()
+	rw := RecognitionOfWar{
+		Attacker: Player{Username: b.Attacker, Units: unitMap(b.AttackerUnits)},
+		Defender: Player{Username: b.Defender, Units: unitMap(b.DefenderUnits)},
+		Allies:   []Player{{Username: "carol", Units: unitMap(b.Allies[0].Units)}},
+		Seed:     42,
+	}
+	want := newBattles(rw, rules)[0]
+
+	for _, p := range []Player{rw.Attacker, rw.Defender, rw.Allies[0], {Username: "dave", Units: map[int]Unit{}}} {
+		gs := NewGameState(p.Username)
+		gs.SetRules(rules)
+		gs.Restore(p)
+		outcome, _ := gs.HandleWar(rw)
+
+		units := gs.GetPlayerSnap().Units
+		for _, u := range want.Losses(p.Username) {
+			if _, ok := units[u.ID]; ok {
+				t.Errorf("%s still has %s %d it lost", p.Username, u.Rank, u.ID)
+			}
+		}
+		if left, lost := len(units), len(want.Losses(p.Username)); left+lost != len(p.Units) {
+			t.Errorf("%s has %d unit(s) left after losing %d of %d", p.Username, left, lost, len(p.Units))
+		}
+		if p.Username == "dave" && outcome != WarOutcomeNotInvolved {
+			t.Errorf("dave has no units in the war, got outcome %v", outcome)
+		}
+		if p.Username == "carol" && outcome != want.OutcomeFor(b.Defender) {
+			t.Errorf("carol should share the outcome of %s, got %v", b.Defender, outcome)
+		}
+	}
+}
+
+func unitMap(units []Unit) map[int]Unit {
+	m := map[int]Unit{}
+	for _, u := range units {
+		m[u.ID] = u
+	}
+	return m
+}
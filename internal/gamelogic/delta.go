@@ -0,0 +1,153 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// SnapshotInterval is how often a player's moves carry their whole army,
+// so everyone catches up even after missing a move.
+const SnapshotInterval = 10
+
+// ErrMoveGap is returned for a delta that doesn't follow the last move seen
+// of its player.
+var ErrMoveGap = errors.New("missed a move")
+
+// ArmySource tells a world the army of a player it lost track of, as a
+// snapshot with the Version of the last move it includes.
+type ArmySource interface {
+	Army(username string) (ArmyMove, error)
+}
+
+// SentArmy is the army as the other players know it from the last move
+// sent of a player. The zero value is ready to use.
+type SentArmy struct {
+	version int
+	units   map[int]Unit
+}
+
+// Stamp numbers a whole move and, between snapshots, shrinks it to a delta
+// against the previous move.
+func (s *SentArmy) Stamp(mv ArmyMove) ArmyMove {
+	s.version++
+	previous := s.units
+	s.units = copyPlayer(mv.Player).Units
+	if previous == nil || s.version%SnapshotInterval == 1 {
+		mv.Version = s.version
+		return mv
+	}
+
+	delta := ArmyMove{
+		Player:     Player{Username: mv.Player.Username},
+		ToLocation: mv.ToLocation,
+		Version:    s.version,
+		UnitIDs:    mv.MovedIDs(),
+	}
+	moved := map[int]bool{}
+	for _, id := range delta.UnitIDs {
+		moved[id] = true
+	}
+	for id, unit := range mv.Player.Units {
+		known, ok := previous[id]
+		if !ok || (!moved[id] && known != unit) {
+			delta.Gained = append(delta.Gained, unit)
+		}
+	}
+	for id := range previous {
+		if _, ok := mv.Player.Units[id]; !ok {
+			delta.Lost = append(delta.Lost, id)
+		}
+	}
+	sortUnits(delta.Gained)
+	sort.Ints(delta.Lost)
+	return delta
+}
+
+// Version is the Version of the last move stamped.
+func (s *SentArmy) Version() int {
+	return s.version
+}
+
+// applyMove applies a move of another player to the world and returns it
+// whole. w.mu must be held.
+func (w *World) applyMove(mv ArmyMove) (ArmyMove, error) {
+	username := mv.Player.Username
+	if !mv.IsDelta() {
+		units := map[int]Unit{}
+		for k, v := range mv.Player.Units {
+			units[k] = v
+		}
+		for _, unit := range mv.Units {
+			units[unit.ID] = unit
+		}
+		w.players[username] = Player{Username: username, Units: units}
+		if mv.Version > 0 {
+			w.versions[username] = mv.Version
+		}
+		return mv, nil
+	}
+
+	p, ok := w.players[username]
+	if !ok || mv.Version != w.versions[username]+1 {
+		return ArmyMove{}, fmt.Errorf("%w of %s: got move %d after move %d", ErrMoveGap, username, mv.Version, w.versions[username])
+	}
+	return w.applyDelta(p, mv), nil
+}
+
+// applyDelta applies a delta on top of p, skipping the units p doesn't
+// have. w.mu must be held.
+func (w *World) applyDelta(p Player, mv ArmyMove) ArmyMove {
+	army := copyPlayer(p)
+	army.Username = mv.Player.Username
+	for _, id := range mv.Lost {
+		delete(army.Units, id)
+	}
+	for _, unit := range mv.Gained {
+		army.Units[unit.ID] = unit
+	}
+	moved := []Unit{}
+	for _, id := range mv.UnitIDs {
+		unit, ok := army.Units[id]
+		if !ok {
+			continue
+		}
+		unit.Location = mv.ToLocation
+		army.Units[id] = unit
+		moved = append(moved, unit)
+	}
+	w.players[army.Username] = army
+	w.versions[army.Username] = mv.Version
+	return ArmyMove{
+		Player:     copyPlayer(army),
+		Units:      moved,
+		ToLocation: mv.ToLocation,
+		Version:    mv.Version,
+	}
+}
+
+// resync replaces the army of the mover with a snapshot from the source,
+// and applies mv on top of it unless the snapshot already includes it.
+// w.mu must be held.
+func (w *World) resync(snapshot, mv ArmyMove) ArmyMove {
+	username := mv.Player.Username
+	army := copyPlayer(snapshot.Player)
+	army.Username = username
+	w.players[username] = army
+	w.versions[username] = snapshot.Version
+	if snapshot.Version < mv.Version {
+		return w.applyDelta(army, mv)
+	}
+	moved := []Unit{}
+	for _, id := range mv.UnitIDs {
+		if unit, ok := army.Units[id]; ok {
+			moved = append(moved, unit)
+		}
+	}
+	return ArmyMove{
+		Player:     copyPlayer(army),
+		Units:      moved,
+		ToLocation: mv.ToLocation,
+		Version:    snapshot.Version,
+	}
+}
@@ -0,0 +1,197 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+var marchRoute = []Location{"europe", "asia", "africa", "americas"}
+
+func testArmy(size int) Player {
+	p := Player{Username: "alice", Units: map[int]Unit{}}
+	for id := 1; id <= size; id++ {
+		p.Units[id] = Unit{ID: id, Rank: RankInfantry, Location: marchRoute[0]}
+	}
+	return p
+}
+
+// march moves one unit of p along marchRoute, a different one every turn,
+// and returns the whole move.
+func march(p Player, turn int) ArmyMove {
+	ids := []int{}
+	for id := range p.Units {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	p = copyPlayer(p)
+	unit := p.Units[ids[turn%len(ids)]]
+	unit.Location = marchRoute[(turn+1)%len(marchRoute)]
+	p.Units[unit.ID] = unit
+	return ArmyMove{Player: p, Units: []Unit{unit}, ToLocation: unit.Location}
+}
+
+// armyAt is an ArmySource that always knows the latest army.
+type armyAt struct {
+	move ArmyMove
+}
+
+func (a *armyAt) Army(string) (ArmyMove, error) {
+	return a.move, nil
+}
+
+func TestStampSnapshotEveryInterval(t *testing.T) {
+	var sent SentArmy
+	p := testArmy(3)
+	for turn := range 3*SnapshotInterval + 1 {
+		mv := march(p, turn)
+		p = mv.Player
+		stamped := sent.Stamp(mv)
+		if stamped.Version != turn+1 {
+			t.Fatalf("move %d was stamped %d", turn+1, stamped.Version)
+		}
+		if snapshot := stamped.Version%SnapshotInterval == 1; stamped.IsDelta() == snapshot {
+			t.Errorf("move %d: snapshot %v, delta %v", stamped.Version, snapshot, stamped.IsDelta())
+		}
+	}
+}
+
+func TestStampApplyMoveRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		// change is applied to the army before every move.
+		change func(turn int, p Player) Player
+	}{
+		{"moves only", func(_ int, p Player) Player { return p }},
+		{"spawns between moves", func(turn int, p Player) Player {
+			p.Units[100+turn] = Unit{ID: 100 + turn, Rank: RankCavalry, Location: "asia"}
+			return p
+		}},
+		{"losses between moves", func(turn int, p Player) Player {
+			if turn%3 == 2 && len(p.Units) > 1 {
+				for id := range p.Units {
+					delete(p.Units, id)
+					break
+				}
+			}
+			return p
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent SentArmy
+			world := NewWorld()
+			p := testArmy(8)
+			for turn := range 2*SnapshotInterval + 3 {
+				mv := march(tt.change(turn, copyPlayer(p)), turn)
+				p = mv.Player
+				rebuilt, err := world.ApplyMove(sent.Stamp(mv))
+				if err != nil {
+					t.Fatalf("move %d: %v", turn+1, err)
+				}
+				if !sameUnits(rebuilt.Player.Units, p.Units) {
+					t.Fatalf("move %d rebuilt %v, want %v", turn+1, rebuilt.Player.Units, p.Units)
+				}
+				if len(rebuilt.Units) != 1 || rebuilt.Units[0] != mv.Units[0] {
+					t.Fatalf("move %d moved %v, want %v", turn+1, rebuilt.Units, mv.Units)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyMoveGap(t *testing.T) {
+	tests := []struct {
+		name string
+		// missed are the versions the world never hears of.
+		missed map[int]bool
+		resync bool
+		// gaps are the versions that must report ErrMoveGap.
+		gaps map[int]bool
+	}{
+		{"nothing missed", nil, false, nil},
+		{"missed a delta", map[int]bool{3: true}, false, map[int]bool{4: true}},
+		{"missed a delta, resync", map[int]bool{3: true}, true, nil},
+		{"missed the first snapshot", map[int]bool{1: true}, false, map[int]bool{2: true}},
+		{"missed the first snapshot, resync", map[int]bool{1: true}, true, nil},
+		{"snapshot catches up", map[int]bool{SnapshotInterval - 1: true, SnapshotInterval: true}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent SentArmy
+			world := NewWorld()
+			source := &armyAt{}
+			if tt.resync {
+				world.SetSource(source)
+			}
+			p := testArmy(4)
+			for turn := range SnapshotInterval + 3 {
+				mv := march(p, turn)
+				p = mv.Player
+				stamped := sent.Stamp(mv)
+				source.move = ArmyMove{Player: p, Version: stamped.Version}
+				if tt.missed[stamped.Version] {
+					continue
+				}
+				_, err := world.ApplyMove(stamped)
+				if gap := errors.Is(err, ErrMoveGap); gap != tt.gaps[stamped.Version] {
+					t.Fatalf("move %d: got %v", stamped.Version, err)
+				}
+				if err != nil {
+					// Without a source the world stays behind until the
+					// next snapshot.
+					return
+				}
+				got, _ := world.Player(p.Username)
+				if !sameUnits(got.Units, p.Units) {
+					t.Fatalf("move %d: world has %v, want %v", stamped.Version, got.Units, p.Units)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkArmyMove(b *testing.B) {
+	for _, size := range []int{5, 50, 200} {
+		b.Run(fmt.Sprintf("units=%d", size), func(b *testing.B) {
+			var sent SentArmy
+			world := NewWorld()
+			p := testArmy(size)
+			sentBytes, intentBytes := 0, 0
+			for turn := range b.N {
+				mv := march(p, turn)
+				p = mv.Player
+				intent, err := json.Marshal(mv.Intent(sent.Version()))
+				if err != nil {
+					b.Fatal(err)
+				}
+				intentBytes += len(intent)
+				stamped := sent.Stamp(mv)
+				data, err := json.Marshal(stamped)
+				if err != nil {
+					b.Fatal(err)
+				}
+				sentBytes += len(data)
+				if _, err := world.ApplyMove(stamped); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(sentBytes)/float64(b.N), "bytes/op")
+			b.ReportMetric(float64(intentBytes)/float64(b.N), "intent-bytes/op")
+		})
+	}
+}
+
+func sameUnits(a, b map[int]Unit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, unit := range a {
+		if b[id] != unit {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// SetDiplomacy updates the pacts of the game without reporting them, e.g.
+// when joining.
+func (gs *GameState) SetDiplomacy(state routing.DiplomacyState) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.diplomacy = state
+}
+
+func (gs *GameState) HandleDiplomacy(state routing.DiplomacyState) {
+	gs.SetDiplomacy(state)
+	gs.emit(DiplomacyChanged{State: state, Player: gs.GetUsername()})
+}
+
+func (gs *GameState) Diplomacy() routing.DiplomacyState {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.diplomacy
+}
+
+// Team returns the team the player is on, if any.
+func (gs *GameState) Team() string {
+	return gs.Diplomacy().Teams[gs.GetUsername()]
+}
+
+// PactWith returns the pact the player has with username, if any.
+// Teammates always have a team pact.
+func (gs *GameState) PactWith(username string) (routing.PactKind, bool) {
+	state := gs.Diplomacy()
+	self := gs.GetUsername()
+	if team := state.Teams[self]; team != "" && state.Teams[username] == team && username != self {
+		return routing.PactTeam, true
+	}
+	for _, pact := range state.Pacts {
+		if pact.Players == pactPlayers(self, username) {
+			return pact.Kind, true
+		}
+	}
+	return "", false
+}
+
+func pactPlayers(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+func (gs *GameState) CommandDiplomacy() {
+	gs.emit(DiplomacyReported{State: gs.Diplomacy(), Player: gs.GetUsername()})
+}
+
+// CommandNegotiate turns a propose, accept or break command into a request
+// for the server, which keeps the pacts of the game.
+func (gs *GameState) CommandNegotiate(words []string) (routing.DiplomacyRequest, error) {
+	if len(words) < 2 {
+		return routing.DiplomacyRequest{}, errors.New("usage: propose <player> alliance|truce, accept <player> or break <player>")
+	}
+	req := routing.DiplomacyRequest{
+		Username: gs.GetUsername(),
+		Action:   routing.DiplomacyAction(words[0]),
+		With:     words[1],
+	}
+	if req.With == req.Username {
+		return routing.DiplomacyRequest{}, errors.New("error: you can't make a pact with yourself")
+	}
+	if kind, ok := gs.PactWith(req.With); ok && kind == routing.PactTeam {
+		return routing.DiplomacyRequest{}, fmt.Errorf("error: %s is on your team", req.With)
+	}
+	switch req.Action {
+	case routing.DiplomacyPropose:
+		if len(words) < 3 {
+			return routing.DiplomacyRequest{}, errors.New("usage: propose <player> alliance|truce")
+		}
+		req.Kind = routing.PactKind(words[2])
+		if req.Kind != routing.PactAlliance && req.Kind != routing.PactTruce {
+			return routing.DiplomacyRequest{}, fmt.Errorf("error: %s is not a pact, propose an alliance or a truce", req.Kind)
+		}
+	case routing.DiplomacyAccept:
+	case routing.DiplomacyBreak:
+		if _, ok := gs.PactWith(req.With); !ok && !gs.proposedTo(req.With) {
+			return routing.DiplomacyRequest{}, fmt.Errorf("error: you have no pact with %s", req.With)
+		}
+	default:
+		return routing.DiplomacyRequest{}, fmt.Errorf("error: unknown diplomacy command %s", req.Action)
+	}
+	return req, nil
+}
+
+func (gs *GameState) proposedTo(username string) bool {
+	self := gs.GetUsername()
+	for _, p := range gs.Diplomacy().Proposals {
+		if p.From == self && p.To == username {
+			return true
+		}
+	}
+	return false
+}
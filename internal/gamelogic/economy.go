@@ -0,0 +1,109 @@
+package gamelogic
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Economy is how much gold players start with and earn. Players are paid
+// every round: for every location they hold a unit in, plus the bonus of
+// every region they hold entirely.
+type Economy struct {
+	StartingGold   int
+	LocationIncome int
+}
+
+func ClassicEconomy() Economy {
+	return Economy{StartingGold: 10, LocationIncome: 2}
+}
+
+func (e Economy) Validate() error {
+	if e.StartingGold < 0 || e.LocationIncome < 0 {
+		return errors.New("starting gold and income can't be negative")
+	}
+	return nil
+}
+
+// Income is what a player earns at the end of a round, and why.
+type Income struct {
+	Locations      []Location
+	LocationIncome int
+	Regions        []Region
+	Total          int
+}
+
+func (r Rules) Income(p Player) Income {
+	seen := map[Location]bool{}
+	income := Income{
+		Locations:      []Location{},
+		LocationIncome: r.Economy.LocationIncome,
+		Regions:        r.Map.HeldRegions(p),
+	}
+	for _, unit := range p.Units {
+		if !seen[unit.Location] {
+			seen[unit.Location] = true
+			income.Locations = append(income.Locations, unit.Location)
+		}
+	}
+	sort.Slice(income.Locations, func(i, j int) bool {
+		return income.Locations[i] < income.Locations[j]
+	})
+	income.Total = len(income.Locations) * income.LocationIncome
+	for _, region := range income.Regions {
+		income.Total += region.Bonus
+	}
+	return income
+}
+
+// Bank pays for the units a player spawns. The server keeps the gold, so a
+// client can't make itself richer by editing its own state. Spend returns
+// the treasury left after paying for unit.
+type Bank interface {
+	Spend(unit Unit) (routing.Treasury, error)
+}
+
+// SetBank makes spawning cost gold. Without a bank, spawns are free.
+func (gs *GameState) SetBank(b Bank) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.bank = b
+}
+
+// Treasury is the last known state of the player's gold, and reports false
+// when spawns are free.
+func (gs *GameState) Treasury() (routing.Treasury, bool) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.treasury, gs.bank != nil
+}
+
+func (gs *GameState) HandleTreasury(t routing.Treasury) {
+	gs.SetTreasury(t)
+	gs.emit(TreasuryChanged{Treasury: t})
+}
+
+// SetTreasury updates the gold without reporting it, e.g. when joining.
+func (gs *GameState) SetTreasury(t routing.Treasury) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.treasury = t
+}
+
+func (gs *GameState) CommandTreasury() {
+	t, banked := gs.Treasury()
+	gs.emit(TreasuryReported{Treasury: t, Free: !banked})
+}
+
+// CommandEconomy reports what the player will earn at the end of the round
+// if nothing changes, and what every unit costs.
+func (gs *GameState) CommandEconomy() {
+	rules := gs.Rules()
+	t, _ := gs.Treasury()
+	gs.emit(EconomyReported{
+		Gold:    t.Gold,
+		Income:  rules.Income(gs.GetPlayerSnap()),
+		Catalog: rules.Catalog,
+	})
+}
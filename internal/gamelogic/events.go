@@ -0,0 +1,216 @@
+package gamelogic
+
+import (
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Event is something that happened to a GameState. Subscribers receive
+// events in the order they happened and decide how to present them.
+type Event interface {
+	EventName() string
+}
+
+type Subscriber interface {
+	Notify(Event)
+}
+
+type SubscriberFunc func(Event)
+
+func (f SubscriberFunc) Notify(e Event) {
+	f(e)
+}
+
+// MoveDetected reports a move by another player. Pact is set when the
+// move is safe because of a pact with the mover.
+type MoveDetected struct {
+	Move      ArmyMove
+	Outcome   MoveOutcome
+	Locations []Location
+	Pact      routing.PactKind
+}
+
+type UnitsMoved struct {
+	Move ArmyMove
+}
+
+// MoveRejected reports a move of the player the server refused, once
+// their army is back to what the server knows.
+type MoveRejected struct {
+	Move   ArmyMove
+	Reason string
+}
+
+type MoveQueued struct {
+	Move  ArmyMove
+	Round int
+}
+
+type OrdersSubmitted struct {
+	Round int
+	Moves int
+}
+
+type RoundChanged struct {
+	State routing.RoundState
+}
+
+type WarDeclared struct {
+	Attacker string
+	Defender string
+}
+
+// WarResolved reports every battle of a war, in the order they were fought.
+type WarResolved struct {
+	Player   string
+	Attacker string
+	Defender string
+	Outcome  WarOutcome
+	Results  []BattleResult
+}
+
+type UnitSpawned struct {
+	Unit Unit
+}
+
+type UnitsLost struct {
+	Location Location
+	Units    []Unit
+}
+
+type PauseChanged struct {
+	Paused bool
+}
+
+type Journaled struct {
+	Entry JournalEntry
+}
+
+type HistoryReported struct {
+	UnitID  int
+	Entries []JournalEntry
+}
+
+type ArmyReported struct {
+	Army Snapshot
+}
+
+type TurnStatus struct {
+	TurnBased bool
+	Round     int
+	Open      bool
+	Deadline  time.Time
+	Orders    int
+	Submitted bool
+}
+
+type StatusReported struct {
+	Paused  bool
+	Player  Player
+	Turn    TurnStatus
+	Regions []Region
+}
+
+type TreasuryChanged struct {
+	Treasury routing.Treasury
+}
+
+type TreasuryReported struct {
+	Treasury routing.Treasury
+	Free     bool
+}
+
+type EconomyReported struct {
+	Gold    int
+	Income  Income
+	Catalog *Catalog
+}
+
+type DiplomacyChanged struct {
+	State  routing.DiplomacyState
+	Player string
+}
+
+type ChatReceived struct {
+	Message routing.ChatMessage
+	Player  string
+}
+
+type GameEnded struct {
+	Over   routing.GameOver
+	Player string
+}
+
+type DiplomacyReported struct {
+	State  routing.DiplomacyState
+	Player string
+}
+
+type CatalogReported struct {
+	Catalog *Catalog
+}
+
+type MapReported struct {
+	Map    *Map
+	Player Player
+}
+
+func (MoveDetected) EventName() string      { return "move_detected" }
+func (UnitsMoved) EventName() string        { return "units_moved" }
+func (MoveRejected) EventName() string      { return "move_rejected" }
+func (MoveQueued) EventName() string        { return "move_queued" }
+func (OrdersSubmitted) EventName() string   { return "orders_submitted" }
+func (RoundChanged) EventName() string      { return "round_changed" }
+func (WarDeclared) EventName() string       { return "war_declared" }
+func (WarResolved) EventName() string       { return "war_resolved" }
+func (UnitSpawned) EventName() string       { return "unit_spawned" }
+func (UnitsLost) EventName() string         { return "units_lost" }
+func (PauseChanged) EventName() string      { return "pause_changed" }
+func (StatusReported) EventName() string    { return "status_reported" }
+func (Journaled) EventName() string         { return "journaled" }
+func (HistoryReported) EventName() string   { return "history_reported" }
+func (ArmyReported) EventName() string      { return "army_reported" }
+func (MapReported) EventName() string       { return "map_reported" }
+func (CatalogReported) EventName() string   { return "catalog_reported" }
+func (TreasuryChanged) EventName() string   { return "treasury_changed" }
+func (TreasuryReported) EventName() string  { return "treasury_reported" }
+func (EconomyReported) EventName() string   { return "economy_reported" }
+func (DiplomacyChanged) EventName() string  { return "diplomacy_changed" }
+func (DiplomacyReported) EventName() string { return "diplomacy_reported" }
+func (ChatReceived) EventName() string      { return "chat_received" }
+func (GameEnded) EventName() string         { return "game_ended" }
+
+// EventNames lists the name of every event a game state emits.
+func EventNames() []string {
+	names := []string{}
+	for _, e := range []Event{
+		MoveDetected{}, UnitsMoved{}, MoveRejected{}, MoveQueued{}, OrdersSubmitted{},
+		RoundChanged{}, WarDeclared{}, WarResolved{}, UnitSpawned{},
+		UnitsLost{}, PauseChanged{}, StatusReported{}, Journaled{},
+		HistoryReported{}, ArmyReported{}, MapReported{}, CatalogReported{},
+		TreasuryChanged{}, TreasuryReported{}, EconomyReported{},
+		DiplomacyChanged{}, DiplomacyReported{}, ChatReceived{}, GameEnded{},
+	} {
+		names = append(names, e.EventName())
+	}
+	return names
+}
+
+func (gs *GameState) Subscribe(s Subscriber) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.subscribers = append(gs.subscribers, s)
+}
+
+// emit must be called without holding gs.mu, since subscribers are free to
+// read the game state while handling an event.
+func (gs *GameState) emit(e Event) {
+	gs.mu.RLock()
+	subscribers := make([]Subscriber, len(gs.subscribers))
+	copy(subscribers, gs.subscribers)
+	gs.mu.RUnlock()
+	for _, s := range subscribers {
+		s.Notify(e)
+	}
+}
@@ -0,0 +1,187 @@
+package gamelogic
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sighting is what a player sees of a move in a game played in fog of war:
+// only the mover's units in or next to the locations the viewer holds.
+// Visible lists those locations. A scouting sighting is not a move, it is
+// what the viewer found around the locations they just moved to.
+type Sighting struct {
+	Viewer  string
+	Move    ArmyMove
+	Visible []Location
+	Scouted bool `json:",omitempty"`
+	SeenAt  time.Time
+}
+
+// Sight returns the locations a player sees: the ones they have units in
+// and their neighbors, in alphabetical order.
+func (m *Map) Sight(p Player) []Location {
+	visible := map[Location]bool{}
+	for _, unit := range p.Units {
+		visible[unit.Location] = true
+		for _, loc := range m.Neighbors(unit.Location) {
+			visible[loc] = true
+		}
+	}
+	locations := []Location{}
+	for loc := range visible {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i] < locations[j]
+	})
+	return locations
+}
+
+// Within keeps only the units of a move that are in the given locations.
+func (mv ArmyMove) Within(locations []Location) ArmyMove {
+	seen := ArmyMove{
+		Player:     unitsWithin(mv.Player, locations),
+		Units:      []Unit{},
+		ToLocation: mv.ToLocation,
+	}
+	for _, unit := range mv.Units {
+		if slices.Contains(locations, unit.Location) {
+			seen.Units = append(seen.Units, unit)
+		}
+	}
+	return seen
+}
+
+func unitsWithin(p Player, locations []Location) Player {
+	seen := Player{Username: p.Username, Units: map[int]Unit{}}
+	for id, unit := range p.Units {
+		if slices.Contains(locations, unit.Location) {
+			seen.Units[id] = unit
+		}
+	}
+	return seen
+}
+
+// Contested keeps only the units fighting in the war. The battles are the
+// same, but the war doesn't carry whole armies, nor reveal them in fog of
+// war.
+func (rw RecognitionOfWar) Contested() RecognitionOfWar {
+	locations := getOverlappingLocations(rw.Attacker, rw.Defender)
+	contested := RecognitionOfWar{
+		Attacker: unitsWithin(rw.Attacker, locations),
+		Defender: unitsWithin(rw.Defender, locations),
+		Seed:     rw.Seed,
+	}
+	for _, ally := range rw.Allies {
+		contested.Allies = append(contested.Allies, unitsWithin(ally, locations))
+	}
+	return contested
+}
+
+// Intel is a player's last known positions of the enemy units they can no
+// longer see, built from their sightings in fog of war.
+type Intel struct {
+	units map[string]map[int]Spotted
+	mu    *sync.RWMutex
+}
+
+// Spotted is an enemy unit as it was last seen.
+type Spotted struct {
+	Owner  string
+	Unit   Unit
+	SeenAt time.Time
+}
+
+func NewIntel() *Intel {
+	return &Intel{
+		units: map[string]map[int]Spotted{},
+		mu:    &sync.RWMutex{},
+	}
+}
+
+// Observe records the units of a sighting. The mover's units last seen in
+// a location the sighting covers but missing from it have left, and are
+// forgotten.
+func (in *Intel) Observe(s Sighting) {
+	if s.Move.Player.Username == s.Viewer {
+		return
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	owner := s.Move.Player.Username
+	known, ok := in.units[owner]
+	if !ok {
+		known = map[int]Spotted{}
+		in.units[owner] = known
+	}
+	for id, spotted := range known {
+		if _, ok := s.Move.Player.Units[id]; !ok && slices.Contains(s.Visible, spotted.Unit.Location) {
+			delete(known, id)
+		}
+	}
+	for id, unit := range s.Move.Player.Units {
+		known[id] = Spotted{Owner: owner, Unit: unit, SeenAt: s.SeenAt}
+	}
+}
+
+// Forget removes the enemy units lost in battles.
+func (in *Intel) Forget(battles []Battle) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	remove := func(owner string, units []Unit) {
+		for _, unit := range units {
+			delete(in.units[owner], unit.ID)
+		}
+	}
+	for _, b := range battles {
+		remove(b.Attacker, b.AttackerLosses)
+		remove(b.Defender, b.DefenderLosses)
+		for _, ally := range b.Allies {
+			remove(ally.Username, ally.Losses)
+		}
+	}
+}
+
+// Spotted returns every enemy unit last seen, by location, owner and ID.
+func (in *Intel) Spotted() []Spotted {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	spotted := []Spotted{}
+	for _, units := range in.units {
+		for _, s := range units {
+			spotted = append(spotted, s)
+		}
+	}
+	sort.Slice(spotted, func(i, j int) bool {
+		a, b := spotted[i], spotted[j]
+		if a.Unit.Location != b.Unit.Location {
+			return a.Unit.Location < b.Unit.Location
+		}
+		if a.Owner != b.Owner {
+			return a.Owner < b.Owner
+		}
+		return a.Unit.ID < b.Unit.ID
+	})
+	return spotted
+}
+
+// Fprint lists the enemy units last seen and how long ago they were seen.
+func (in *Intel) Fprint(w io.Writer, now time.Time) {
+	spotted := in.Spotted()
+	if len(spotted) == 0 {
+		fmt.Fprintln(w, "No enemy units have been seen yet.")
+		return
+	}
+	var loc Location
+	for _, s := range spotted {
+		if s.Unit.Location != loc {
+			loc = s.Unit.Location
+			fmt.Fprintf(w, "%s:\n", loc)
+		}
+		fmt.Fprintf(w, "  * %s: %s %d, seen %s ago\n", s.Owner, s.Unit.Rank, s.Unit.ID, now.Sub(s.SeenAt).Round(time.Second))
+	}
+}
@@ -19,34 +19,100 @@ type Unit struct {
 	Location Location
 }
 
+// ArmyMove is a move as published. Moves are numbered per player by
+// Version, from 1. Every SnapshotInterval moves, starting with the first,
+// a move is a snapshot: Player is the mover's whole army and Units the
+// moved units. The others are deltas against the previous move: UnitIDs
+// are the moved units, Gained the units the mover spawned or otherwise
+// changed since, and Lost the IDs of the units they lost. Moves without a
+// Version are snapshots.
 type ArmyMove struct {
 	Player     Player
-	Units      []Unit
+	Units      []Unit `json:",omitempty"`
 	ToLocation Location
+	Version    int    `json:",omitempty"`
+	UnitIDs    []int  `json:",omitempty"`
+	Gained     []Unit `json:",omitempty"`
+	Lost       []int  `json:",omitempty"`
 }
 
-type RecognitionOfWar struct {
-	Attacker Player
-	Defender Player
+func (mv ArmyMove) IsDelta() bool {
+	return len(mv.UnitIDs) > 0
 }
 
-type Location string
+// MovedIDs returns the IDs of the moved units, of a snapshot or a delta.
+func (mv ArmyMove) MovedIDs() []int {
+	if mv.IsDelta() {
+		return mv.UnitIDs
+	}
+	ids := []int{}
+	for _, unit := range mv.Units {
+		ids = append(ids, unit.ID)
+	}
+	return ids
+}
+
+// MoveIntent is a move as a player sends it to the server, which knows
+// the armies: the IDs of the units moved and where to. Base is the Version
+// of the last move of theirs the player heard back, the army the move was
+// planned on. Token is the player's registration token.
+type MoveIntent struct {
+	Username   string
+	Token      string
+	ToLocation Location
+	UnitIDs    []int
+	Base       int `json:",omitempty"`
+}
 
-func getAllRanks() map[UnitRank]struct{} {
-	return map[UnitRank]struct{}{
-		RankInfantry:  {},
-		RankCavalry:   {},
-		RankArtillery: {},
+// Intent is the move as its player sends it to the server.
+func (mv ArmyMove) Intent(base int) MoveIntent {
+	return MoveIntent{
+		Username:   mv.Player.Username,
+		ToLocation: mv.ToLocation,
+		UnitIDs:    mv.MovedIDs(),
+		Base:       base,
 	}
 }
 
-func getAllLocations() map[Location]struct{} {
-	return map[Location]struct{}{
-		"americas":   {},
-		"europe":     {},
-		"africa":     {},
-		"asia":       {},
-		"australia":  {},
-		"antarctica": {},
+// Move is the intent as a delta of its moved units, e.g. to show a player
+// which of their moves was refused.
+func (in MoveIntent) Move() ArmyMove {
+	return ArmyMove{
+		Player:     Player{Username: in.Username},
+		ToLocation: in.ToLocation,
+		UnitIDs:    in.UnitIDs,
 	}
 }
+
+// OrderSet is the moves a player orders for a round in turn mode. Token is
+// the player's registration token.
+type OrderSet struct {
+	Game     string
+	Username string
+	Token    string
+	Round    int
+	Moves    []MoveIntent
+}
+
+// WarDeclaration is sent by a defender to the server when an attacker
+// moved into one of their locations. The server brings the armies. Token
+// is the defender's registration token.
+type WarDeclaration struct {
+	Attacker string
+	Defender string
+	Token    string
+	Location Location
+}
+
+// RecognitionOfWar is a declared war as the server publishes it, with the
+// armies fighting it as the server knows them. Seed drives the dice of the
+// war, so everyone resolving it rolls the same. Allies are the armies of
+// the defender's allies, which defend alongside it.
+type RecognitionOfWar struct {
+	Attacker Player
+	Defender Player
+	Allies   []Player `json:",omitempty"`
+	Seed     int64
+}
+
+type Location string
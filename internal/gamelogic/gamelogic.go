@@ -4,25 +4,65 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
 )
 
 func PrintClientHelp() {
-	fmt.Println("Possible commands:")
-	fmt.Println("* move <location> <unitID> <unitID> <unitID>...")
-	fmt.Println("    example:")
-	fmt.Println("    move asia 1")
-	fmt.Println("* spawn <location> <rank>")
-	fmt.Println("    example:")
-	fmt.Println("    spawn europe infantry")
-	fmt.Println("* status")
-	fmt.Println("* spam <n>")
-	fmt.Println("    example:")
-	fmt.Println("    spam 5")
-	fmt.Println("* quit")
-	fmt.Println("* help")
+	FprintClientHelp(os.Stdout)
+}
+
+func FprintClientHelp(w io.Writer) {
+	fmt.Fprintln(w, "Possible commands:")
+	fmt.Fprintln(w, "* move <location> <unitID> <unitID> <unitID>...")
+	fmt.Fprintln(w, "    units cross as many borders as their movement allows")
+	fmt.Fprintln(w, "    example:")
+	fmt.Fprintln(w, "    move asia 1")
+	fmt.Fprintln(w, "* spawn <location> <rank>")
+	fmt.Fprintln(w, "    costs the gold listed by economy")
+	fmt.Fprintln(w, "    example:")
+	fmt.Fprintln(w, "    spawn europe infantry")
+	fmt.Fprintln(w, "* submit")
+	fmt.Fprintln(w, "    ends your turn when the game is in turn mode")
+	fmt.Fprintln(w, "* status")
+	fmt.Fprintln(w, "* map")
+	fmt.Fprintln(w, "    lists the territories, their borders and regions")
+	fmt.Fprintln(w, "* units")
+	fmt.Fprintln(w, "    lists the unit types you can spawn and their stats")
+	fmt.Fprintln(w, "* treasury")
+	fmt.Fprintln(w, "    shows your gold")
+	fmt.Fprintln(w, "* economy")
+	fmt.Fprintln(w, "    shows what you will earn this round and what units cost")
+	fmt.Fprintln(w, "* propose <player> alliance|truce")
+	fmt.Fprintln(w, "    neither side fights the other, allies also defend each other")
+	fmt.Fprintln(w, "    example:")
+	fmt.Fprintln(w, "    propose bob alliance")
+	fmt.Fprintln(w, "* accept <player>")
+	fmt.Fprintln(w, "* break <player>")
+	fmt.Fprintln(w, "    ends a pact, or withdraws a proposal")
+	fmt.Fprintln(w, "* diplomacy")
+	fmt.Fprintln(w, "    lists the pacts and teams of the game")
+	fmt.Fprintln(w, "* intel")
+	fmt.Fprintln(w, "    in fog of war, lists where enemy units were last seen and when")
+	fmt.Fprintln(w, "* say <message>")
+	fmt.Fprintln(w, "    sends a message to everyone in the game")
+	fmt.Fprintln(w, "* whisper <player> <message>")
+	fmt.Fprintln(w, "    sends a message to a single player")
+	fmt.Fprintln(w, "* team <message>")
+	fmt.Fprintln(w, "    sends a message to your teammates")
+	fmt.Fprintln(w, "* history [unitID]")
+	fmt.Fprintln(w, "    lists every change to your army, or to a single unit")
+	fmt.Fprintln(w, "* army <entry>")
+	fmt.Fprintln(w, "    shows your army as it was after a history entry")
+	fmt.Fprintln(w, "* spam <n>")
+	fmt.Fprintln(w, "    example:")
+	fmt.Fprintln(w, "    spam 5")
+	fmt.Fprintln(w, "* quit")
+	fmt.Fprintln(w, "* help")
 }
 
 func ClientWelcome() (string, error) {
@@ -34,28 +74,94 @@ func ClientWelcome() (string, error) {
 	}
 	username := words[0]
 	fmt.Printf("Welcome, %s!\n", username)
-	PrintClientHelp()
+	PrintLobbyHelp()
 	return username, nil
 }
 
+func PrintLobbyHelp() {
+	fmt.Println("Possible commands:")
+	fmt.Println("* games")
+	fmt.Println("* join <game> [team]")
+	fmt.Println("    teammates never fight each other and win together")
+	fmt.Println("    example:")
+	fmt.Println("    join alpha red")
+	fmt.Println("* quit")
+	fmt.Println("* help")
+}
+
+func PrintSpectatorHelp() {
+	fmt.Println("Possible commands:")
+	fmt.Println("* map")
+	fmt.Println("    shows every player's units by location")
+	fmt.Println("* quit")
+	fmt.Println("* help")
+}
+
 func PrintServerHelp() {
 	fmt.Println("Possible commands:")
-	fmt.Println("* pause")
-	fmt.Println("* resume")
+	fmt.Println("* games")
+	fmt.Println("* players")
+	fmt.Println("* create <game> [maxPlayers] [map file] [units file]")
+	fmt.Println("    example:")
+	fmt.Println("    create alpha 4 maps/archipelago.yaml units/skirmish.yaml")
+	fmt.Println("* start <game>")
+	fmt.Println("* end <game>")
+	fmt.Println("* team <game> <username> [team]")
+	fmt.Println("    picks a player's team before the game starts, none if left out")
+	fmt.Println("* kick <game> <username>")
+	fmt.Println("* pause <game>")
+	fmt.Println("* resume <game>")
+	fmt.Println("* turns <game> on [seconds]")
+	fmt.Println("* turns <game> off")
 	fmt.Println("* quit")
 	fmt.Println("* help")
 }
 
+func PrintGames(games []routing.GameSummary) {
+	if len(games) == 0 {
+		fmt.Println("There are no games yet.")
+		return
+	}
+	for _, game := range games {
+		status := string(game.Status)
+		if game.Status == routing.GameStatusRunning && game.Paused {
+			status += ", paused"
+		}
+		fmt.Printf("* %s (%s, map %s, %s units, %s combat) %d/%d players", game.Name, status, game.Map, game.Catalog, game.Combat, len(game.Players), game.MaxPlayers)
+		if len(game.Players) > 0 {
+			players := []string{}
+			for _, username := range game.Players {
+				if team := game.Teams[username]; team != "" {
+					username += " (" + team + ")"
+				}
+				players = append(players, username)
+			}
+			fmt.Printf(": %s", strings.Join(players, ", "))
+		}
+		fmt.Println()
+	}
+}
+
+var stdin = bufio.NewScanner(os.Stdin)
+
 func GetInput() []string {
+	words, _ := ReadInput()
+	return words
+}
+
+// ReadInput prompts for a line on stdin and splits it into words. It returns
+// io.EOF once stdin has been closed.
+func ReadInput() ([]string, error) {
 	fmt.Print("> ")
-	scanner := bufio.NewScanner(os.Stdin)
-	scanned := scanner.Scan()
-	if !scanned {
-		return nil
+	if !stdin.Scan() {
+		if err := stdin.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
 	}
-	line := scanner.Text()
+	line := stdin.Text()
 	line = strings.TrimSpace(line)
-	return strings.Fields(line)
+	return strings.Fields(line), nil
 }
 
 func GetMaliciousLog() string {
@@ -73,20 +179,27 @@ func GetMaliciousLog() string {
 }
 
 func PrintQuit() {
-	fmt.Println("I hate this game! (╯°□°)╯︵ ┻━┻")
+	FprintQuit(os.Stdout)
+}
+
+func FprintQuit(w io.Writer) {
+	fmt.Fprintln(w, "I hate this game! (╯°□°)╯︵ ┻━┻")
 }
 
 func (gs *GameState) CommandStatus() {
-	if gs.isPaused() {
-		fmt.Println("The game is paused.")
-		return
-	} else {
-		fmt.Println("The game is not paused.")
-	}
+	player := gs.GetPlayerSnap()
+	gs.emit(StatusReported{
+		Paused:  gs.IsPaused(),
+		Player:  player,
+		Turn:    gs.Turn(),
+		Regions: gs.Map().HeldRegions(player),
+	})
+}
 
-	p := gs.GetPlayerSnap()
-	fmt.Printf("You are %s, and you have %d units.\n", p.Username, len(p.Units))
-	for _, unit := range p.Units {
-		fmt.Printf("* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
-	}
+func (gs *GameState) CommandUnits() {
+	gs.emit(CatalogReported{Catalog: gs.Catalog()})
+}
+
+func (gs *GameState) CommandMap() {
+	gs.emit(MapReported{Map: gs.Map(), Player: gs.GetPlayerSnap()})
 }
@@ -1,13 +1,29 @@
 package gamelogic
 
 import (
+	"sort"
 	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
 )
 
 type GameState struct {
-	Player Player
-	Paused bool
-	mu     *sync.RWMutex
+	Player     Player
+	Paused     bool
+	pauseSeq   int
+	turn       turnState
+	heardRound bool
+	rules      Rules
+	bank       Bank
+	treasury   routing.Treasury
+	diplomacy  routing.DiplomacyState
+	lastUnitID int
+	// version is the Version of the last own move heard back.
+	version     int
+	base        Snapshot
+	journal     []JournalEntry
+	subscribers []Subscriber
+	mu          *sync.RWMutex
 }
 
 func NewGameState(username string) *GameState {
@@ -17,48 +33,112 @@ func NewGameState(username string) *GameState {
 			Units:    map[int]Unit{},
 		},
 		Paused: false,
+		rules:  ClassicRules(),
+		base:   Snapshot{Player: Player{Username: username, Units: map[int]Unit{}}},
 		mu:     &sync.RWMutex{},
 	}
 }
 
-func (gs *GameState) resumeGame() {
+// Rules are the classic rules until SetRules is called with the ones of
+// the game.
+func (gs *GameState) Rules() Rules {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.rules
+}
+
+func (gs *GameState) SetRules(r Rules) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	gs.Paused = false
+	gs.rules = r
+}
+
+func (gs *GameState) Map() *Map {
+	return gs.Rules().Map
+}
+
+func (gs *GameState) Catalog() *Catalog {
+	return gs.Rules().Catalog
+}
+
+func (gs *GameState) resumeGame() {
+	gs.setPaused(false)
 }
 
 func (gs *GameState) pauseGame() {
+	gs.setPaused(true)
+}
+
+func (gs *GameState) setPaused(paused bool) {
 	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Paused = true
+	if gs.Paused == paused {
+		gs.mu.Unlock()
+		return
+	}
+	kind := JournalResumed
+	if paused {
+		kind = JournalPaused
+	}
+	e := gs.record(JournalEntry{Kind: kind})
+	gs.mu.Unlock()
+	gs.emit(Journaled{Entry: e})
 }
 
-func (gs *GameState) isPaused() bool {
+func (gs *GameState) IsPaused() bool {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
 	return gs.Paused
 }
 
-func (gs *GameState) addUnit(u Unit) {
+// nextUnitID never hands out an ID twice, even after units were lost in
+// wars, so other players can tell a new unit from one they saw before.
+func (gs *GameState) nextUnitID() int {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
+	gs.lastUnitID++
+	return gs.lastUnitID
 }
 
-func (gs *GameState) removeUnitsInLocation(loc Location) {
+func (gs *GameState) addUnit(u Unit) {
 	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	for k, v := range gs.Player.Units {
-		if v.Location == loc {
-			delete(gs.Player.Units, k)
+	e := gs.record(JournalEntry{Kind: JournalUnitSpawned, Unit: u})
+	gs.mu.Unlock()
+	gs.emit(Journaled{Entry: e})
+}
+
+// removeUnits destroys those of units the player still has. The reason is
+// kept in the journal.
+func (gs *GameState) removeUnits(units []Unit, reason string) []Unit {
+	gs.mu.Lock()
+	removed := []Unit{}
+	for _, u := range units {
+		if v, ok := gs.Player.Units[u.ID]; ok {
+			removed = append(removed, v)
 		}
 	}
+	if len(removed) == 0 {
+		gs.mu.Unlock()
+		return removed
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].ID < removed[j].ID
+	})
+	e := gs.record(JournalEntry{Kind: JournalUnitsDestroyed, Units: removed, Reason: reason})
+	gs.mu.Unlock()
+	gs.emit(Journaled{Entry: e})
+	return removed
 }
 
 func (gs *GameState) UpdateUnit(u Unit) {
 	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
+	old, ok := gs.Player.Units[u.ID]
+	if ok && old == u {
+		gs.mu.Unlock()
+		return
+	}
+	e := gs.record(JournalEntry{Kind: JournalUnitMoved, Unit: u, From: old.Location})
+	gs.mu.Unlock()
+	gs.emit(Journaled{Entry: e})
 }
 
 func (gs *GameState) GetUsername() string {
@@ -94,3 +174,19 @@ func (gs *GameState) GetPlayerSnap() Player {
 		Units:    Units,
 	}
 }
+
+// Restore replaces the player's units with the ones in p, e.g. an army
+// known from a recording. Spawning continues after the highest unit ID.
+func (gs *GameState) Restore(p Player) {
+	units := []Unit{}
+	for _, v := range p.Units {
+		units = append(units, v)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	gs.mu.Lock()
+	e := gs.record(JournalEntry{Kind: JournalRestored, Units: units})
+	gs.mu.Unlock()
+	gs.emit(Journaled{Entry: e})
+}
@@ -0,0 +1,47 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// CommandHistory reports the journal, or only the entries of one unit to
+// see how it got where it is, or how it disappeared.
+func (gs *GameState) CommandHistory(words []string) error {
+	entries := gs.Journal()
+	if len(words) < 2 {
+		gs.emit(HistoryReported{Entries: entries})
+		return nil
+	}
+
+	id, err := strconv.Atoi(words[1])
+	if err != nil {
+		return fmt.Errorf("error: %s is not a valid unit ID", words[1])
+	}
+	unitEntries := []JournalEntry{}
+	for _, e := range entries {
+		if e.Involves(id) {
+			unitEntries = append(unitEntries, e)
+		}
+	}
+	gs.emit(HistoryReported{UnitID: id, Entries: unitEntries})
+	return nil
+}
+
+// CommandArmy reports the army as it was right after a journal entry.
+func (gs *GameState) CommandArmy(words []string) error {
+	if len(words) < 2 {
+		return errors.New("usage: army <entry>")
+	}
+	seq, err := strconv.Atoi(words[1])
+	if err != nil || seq < 0 {
+		return fmt.Errorf("error: %s is not a valid journal entry", words[1])
+	}
+	army, err := gs.ArmyAt(seq)
+	if err != nil {
+		return err
+	}
+	gs.emit(ArmyReported{Army: army})
+	return nil
+}
@@ -0,0 +1,216 @@
+package gamelogic
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type JournalKind string
+
+const (
+	JournalUnitSpawned    JournalKind = "unit_spawned"
+	JournalUnitMoved      JournalKind = "unit_moved"
+	JournalUnitsDestroyed JournalKind = "units_destroyed"
+	JournalPaused         JournalKind = "paused"
+	JournalResumed        JournalKind = "resumed"
+	JournalRestored       JournalKind = "restored"
+)
+
+// JournalEntry is one change to a player's state. A GameState only changes
+// by appending entries to its journal, so replaying the journal from the
+// start rebuilds the state at any point in time.
+type JournalEntry struct {
+	Seq    int
+	Time   time.Time
+	Kind   JournalKind
+	Unit   Unit
+	From   Location `json:",omitempty"`
+	Units  []Unit   `json:",omitempty"`
+	Reason string   `json:",omitempty"`
+}
+
+func (e JournalEntry) String() string {
+	prefix := fmt.Sprintf("#%d %s", e.Seq, e.Time.Format(time.TimeOnly))
+	switch e.Kind {
+	case JournalUnitSpawned:
+		return fmt.Sprintf("%s spawned %s %d in %s", prefix, e.Unit.Rank, e.Unit.ID, e.Unit.Location)
+	case JournalUnitMoved:
+		return fmt.Sprintf("%s moved %s %d from %s to %s", prefix, e.Unit.Rank, e.Unit.ID, e.From, e.Unit.Location)
+	case JournalUnitsDestroyed:
+		return fmt.Sprintf("%s lost %s: %s", prefix, describeUnits(e.Units), e.Reason)
+	case JournalPaused:
+		return prefix + " game paused"
+	case JournalResumed:
+		return prefix + " game resumed"
+	case JournalRestored:
+		return fmt.Sprintf("%s army restored with %s", prefix, describeUnits(e.Units))
+	}
+	return fmt.Sprintf("%s %s", prefix, e.Kind)
+}
+
+// Involves reports whether the entry changed the unit with the given ID.
+func (e JournalEntry) Involves(id int) bool {
+	if (e.Kind == JournalUnitSpawned || e.Kind == JournalUnitMoved) && e.Unit.ID == id {
+		return true
+	}
+	for _, u := range e.Units {
+		if u.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func describeUnits(units []Unit) string {
+	if len(units) == 0 {
+		return "no units"
+	}
+	parts := []string{}
+	for _, u := range units {
+		parts = append(parts, fmt.Sprintf("%s %d", u.Rank, u.ID))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Snapshot is a player's state as of the journal entry Seq. Pause and round
+// state are sent again by the server when a client reconnects, so Load
+// ignores Paused.
+type Snapshot struct {
+	Player     Player
+	Paused     bool
+	LastUnitID int
+	Seq        int
+}
+
+func (s *Snapshot) apply(e JournalEntry) {
+	switch e.Kind {
+	case JournalUnitSpawned:
+		s.Player.Units[e.Unit.ID] = e.Unit
+		s.LastUnitID = max(s.LastUnitID, e.Unit.ID)
+	case JournalUnitMoved:
+		s.Player.Units[e.Unit.ID] = e.Unit
+	case JournalUnitsDestroyed:
+		for _, u := range e.Units {
+			delete(s.Player.Units, u.ID)
+		}
+	case JournalPaused:
+		s.Paused = true
+	case JournalResumed:
+		s.Paused = false
+	case JournalRestored:
+		s.Player.Units = map[int]Unit{}
+		for _, u := range e.Units {
+			s.Player.Units[u.ID] = u
+			s.LastUnitID = max(s.LastUnitID, u.ID)
+		}
+	}
+	s.Seq = e.Seq
+}
+
+// Replay applies the entries that come after the snapshot and returns the
+// resulting state. The snapshot itself is left untouched.
+func (s Snapshot) Replay(entries []JournalEntry) Snapshot {
+	s.Player = copyPlayer(s.Player)
+	for _, e := range entries {
+		if e.Seq > s.Seq {
+			s.apply(e)
+		}
+	}
+	return s
+}
+
+// record appends an entry to the journal and applies it. gs.mu must be held.
+func (gs *GameState) record(e JournalEntry) JournalEntry {
+	state := gs.snapshot()
+	e.Seq = state.Seq + 1
+	e.Time = time.Now()
+	state.apply(e)
+	gs.Player = state.Player
+	gs.Paused = state.Paused
+	gs.lastUnitID = state.LastUnitID
+	gs.journal = append(gs.journal, e)
+	return e
+}
+
+// snapshot shares the units map with gs. gs.mu must be held.
+func (gs *GameState) snapshot() Snapshot {
+	seq := gs.base.Seq
+	if len(gs.journal) > 0 {
+		seq = gs.journal[len(gs.journal)-1].Seq
+	}
+	return Snapshot{
+		Player:     gs.Player,
+		Paused:     gs.Paused,
+		LastUnitID: gs.lastUnitID,
+		Seq:        seq,
+	}
+}
+
+func (gs *GameState) Snapshot() Snapshot {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	s := gs.snapshot()
+	s.Player = copyPlayer(s.Player)
+	return s
+}
+
+// Load restores a snapshot taken by a GameState of the same player.
+func (gs *GameState) Load(s Snapshot) {
+	gs.Resume(s, nil)
+}
+
+// Resume rebuilds the state from a snapshot and the journal entries saved
+// with it. When the entries go back to the very first one the whole history
+// stays available, otherwise it starts at the snapshot.
+func (gs *GameState) Resume(s Snapshot, entries []JournalEntry) {
+	state := s.Replay(entries)
+
+	gs.mu.Lock()
+	full := len(entries) > 0 && entries[0].Seq == 1
+	if full {
+		gs.base = Snapshot{Player: Player{Username: gs.Player.Username, Units: map[int]Unit{}}}
+		gs.journal = append([]JournalEntry{}, entries...)
+	} else {
+		gs.base = s.Replay(nil)
+		gs.journal = []JournalEntry{}
+		for _, e := range entries {
+			if e.Seq > s.Seq {
+				gs.journal = append(gs.journal, e)
+			}
+		}
+	}
+	gs.Player.Units = state.Player.Units
+	gs.lastUnitID = max(gs.lastUnitID, state.LastUnitID)
+	if full || len(gs.journal) > 0 || s.Seq > 0 {
+		gs.mu.Unlock()
+		return
+	}
+	gs.mu.Unlock()
+
+	// A snapshot without a journal, e.g. saved before journals existed: the
+	// journal starts over from it, so it is recorded as its first entry.
+	gs.Restore(state.Player)
+}
+
+func (gs *GameState) Journal() []JournalEntry {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return append([]JournalEntry{}, gs.journal...)
+}
+
+// ArmyAt rebuilds the player's army as it was right after entry seq.
+func (gs *GameState) ArmyAt(seq int) (Snapshot, error) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	if seq < gs.base.Seq {
+		return Snapshot{}, fmt.Errorf("history before entry %d is not available", gs.base.Seq)
+	}
+	entries := []JournalEntry{}
+	for _, e := range gs.journal {
+		if e.Seq <= seq {
+			entries = append(entries, e)
+		}
+	}
+	return gs.base.Replay(entries), nil
+}
@@ -0,0 +1,165 @@
+package gamelogic
+
+import (
+	"reflect"
+	"testing"
+)
+
+// journal spawns infantry 1 in europe and cavalry 2 in asia, moves
+// infantry 1 to asia, pauses, and loses cavalry 2 in a war.
+var journal = []JournalEntry{
+	{Seq: 1, Kind: JournalUnitSpawned, Unit: Unit{ID: 1, Rank: RankInfantry, Location: "europe"}},
+	{Seq: 2, Kind: JournalUnitSpawned, Unit: Unit{ID: 2, Rank: RankCavalry, Location: "asia"}},
+	{Seq: 3, Kind: JournalUnitMoved, Unit: Unit{ID: 1, Rank: RankInfantry, Location: "asia"}, From: "europe"},
+	{Seq: 4, Kind: JournalPaused},
+	{Seq: 5, Kind: JournalUnitsDestroyed, Units: []Unit{{ID: 2, Rank: RankCavalry, Location: "asia"}}, Reason: "lost a war"},
+}
+
+func TestSnapshotReplay(t *testing.T) {
+	empty := Snapshot{Player: Player{Username: "alice", Units: map[int]Unit{}}}
+	tests := []struct {
+		name    string
+		from    Snapshot
+		entries []JournalEntry
+		want    Snapshot
+	}{
+		{
+			name: "nothing to replay",
+			from: empty,
+			want: empty,
+		},
+		{
+			name:    "spawns",
+			from:    empty,
+			entries: journal[:2],
+			want: Snapshot{
+				Player: Player{Username: "alice", Units: map[int]Unit{
+					1: {ID: 1, Rank: RankInfantry, Location: "europe"},
+					2: {ID: 2, Rank: RankCavalry, Location: "asia"},
+				}},
+				LastUnitID: 2,
+				Seq:        2,
+			},
+		},
+		{
+			name:    "whole journal",
+			from:    empty,
+			entries: journal,
+			want: Snapshot{
+				Player:     Player{Username: "alice", Units: map[int]Unit{1: {ID: 1, Rank: RankInfantry, Location: "asia"}}},
+				Paused:     true,
+				LastUnitID: 2,
+				Seq:        5,
+			},
+		},
+		{
+			name: "entries the snapshot already has are skipped",
+			from: Snapshot{
+				Player:     Player{Username: "alice", Units: map[int]Unit{1: {ID: 1, Rank: RankInfantry, Location: "asia"}}},
+				LastUnitID: 2,
+				Seq:        3,
+			},
+			entries: journal,
+			want: Snapshot{
+				Player:     Player{Username: "alice", Units: map[int]Unit{1: {ID: 1, Rank: RankInfantry, Location: "asia"}}},
+				Paused:     true,
+				LastUnitID: 2,
+				Seq:        5,
+			},
+		},
+		{
+			name: "restored army",
+			from: empty,
+			entries: []JournalEntry{
+				journal[0],
+				{Seq: 2, Kind: JournalRestored, Units: []Unit{{ID: 7, Rank: RankArtillery, Location: "africa"}}},
+				{Seq: 3, Kind: JournalResumed},
+			},
+			want: Snapshot{
+				Player:     Player{Username: "alice", Units: map[int]Unit{7: {ID: 7, Rank: RankArtillery, Location: "africa"}}},
+				LastUnitID: 7,
+				Seq:        3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.from.Replay(nil)
+			got := tt.from.Replay(tt.entries)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Replay() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.from, before) {
+				t.Errorf("Replay() changed the snapshot to %+v", tt.from)
+			}
+		})
+	}
+}
+
+func TestResume(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        Snapshot
+		entries     []JournalEntry
+		wantJournal int
+		wantUnits   map[int]Unit
+		// wantAt is the army after entry 2, when it is still known.
+		wantAt map[int]Unit
+	}{
+		{
+			name:        "whole journal",
+			from:        Snapshot{Player: Player{Username: "alice", Units: map[int]Unit{}}},
+			entries:     journal,
+			wantJournal: 5,
+			wantUnits:   map[int]Unit{1: {ID: 1, Rank: RankInfantry, Location: "asia"}},
+			wantAt: map[int]Unit{
+				1: {ID: 1, Rank: RankInfantry, Location: "europe"},
+				2: {ID: 2, Rank: RankCavalry, Location: "asia"},
+			},
+		},
+		{
+			name: "snapshot and the entries after it",
+			from: Snapshot{
+				Player: Player{Username: "alice", Units: map[int]Unit{
+					1: {ID: 1, Rank: RankInfantry, Location: "asia"},
+					2: {ID: 2, Rank: RankCavalry, Location: "asia"},
+				}},
+				LastUnitID: 2,
+				Seq:        3,
+			},
+			entries:     journal[3:],
+			wantJournal: 2,
+			wantUnits:   map[int]Unit{1: {ID: 1, Rank: RankInfantry, Location: "asia"}},
+		},
+		{
+			name: "snapshot without a journal",
+			from: Snapshot{
+				Player:     Player{Username: "alice", Units: map[int]Unit{1: {ID: 1, Rank: RankInfantry, Location: "asia"}}},
+				LastUnitID: 1,
+			},
+			wantJournal: 1,
+			wantUnits:   map[int]Unit{1: {ID: 1, Rank: RankInfantry, Location: "asia"}},
+			wantAt:      map[int]Unit{1: {ID: 1, Rank: RankInfantry, Location: "asia"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := NewGameState("alice")
+			gs.Resume(tt.from, tt.entries)
+
+			if got := len(gs.Journal()); got != tt.wantJournal {
+				t.Errorf("journal has %d entries, want %d", got, tt.wantJournal)
+			}
+			if got := gs.Snapshot().Player.Units; !reflect.DeepEqual(got, tt.wantUnits) {
+				t.Errorf("units = %v, want %v", got, tt.wantUnits)
+			}
+			at, err := gs.ArmyAt(2)
+			if (err != nil) != (tt.wantAt == nil) {
+				t.Fatalf("ArmyAt() error = %v, want error %v", err, tt.wantAt == nil)
+			}
+			if err == nil && !reflect.DeepEqual(at.Player.Units, tt.wantAt) {
+				t.Errorf("ArmyAt() = %v, want %v", at.Player.Units, tt.wantAt)
+			}
+		})
+	}
+}
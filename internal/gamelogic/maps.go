@@ -0,0 +1,267 @@
+package gamelogic
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Map is the board a game is played on. Units only move along edges, as
+// many per move as their movement allows, so reaching a territory further
+// away takes several turns.
+type Map struct {
+	Name        string
+	Territories []Territory
+	Edges       [][2]Location
+	Regions     []Region `json:",omitempty"`
+
+	adjacent map[Location][]Location
+	regionOf map[Location]string
+}
+
+type Territory struct {
+	Name   Location
+	Region string `json:",omitempty"`
+}
+
+// Region is a group of territories. Holding every one of them is worth
+// Bonus to the player.
+type Region struct {
+	Name  string
+	Bonus int
+}
+
+//go:embed maps/classic.json
+var classicMapData []byte
+
+var classicMap = mustParseMap(classicMapData)
+
+// ClassicMap is the six continents the game has always been played on.
+func ClassicMap() *Map {
+	return classicMap
+}
+
+func mustParseMap(data []byte) *Map {
+	m, err := ParseMap(data)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// LoadMap reads a map from a .json, .yaml or .yml file and validates it.
+func LoadMap(path string) (*Map, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read map: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		m := &Map{}
+		if err := yaml.Unmarshal(data, m); err != nil {
+			return nil, fmt.Errorf("couldn't parse map %s: %v", path, err)
+		}
+		return m, m.Validate()
+	case ".json":
+		return ParseMap(data)
+	}
+	return nil, fmt.Errorf("unsupported map file %s, expected .json, .yaml or .yml", path)
+}
+
+// ParseMap decodes and validates a map in JSON, the format maps are sent
+// to clients in.
+func ParseMap(data []byte) (*Map, error) {
+	m := &Map{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("couldn't parse map: %v", err)
+	}
+	return m, m.Validate()
+}
+
+// Validate checks that the map is playable and indexes its edges. Maps
+// must be validated before they are used.
+func (m *Map) Validate() error {
+	if m.Name == "" {
+		return errors.New("map has no name")
+	}
+	if len(m.Territories) < 2 {
+		return fmt.Errorf("map %s needs at least 2 territories", m.Name)
+	}
+
+	regions := map[string]bool{}
+	for _, r := range m.Regions {
+		if r.Name == "" {
+			return fmt.Errorf("map %s has a region without a name", m.Name)
+		}
+		if regions[r.Name] {
+			return fmt.Errorf("map %s: region %s is defined twice", m.Name, r.Name)
+		}
+		if r.Bonus < 0 {
+			return fmt.Errorf("map %s: region %s has a negative bonus", m.Name, r.Name)
+		}
+		regions[r.Name] = true
+	}
+
+	m.adjacent = map[Location][]Location{}
+	m.regionOf = map[Location]string{}
+	for _, t := range m.Territories {
+		if t.Name == "" || strings.ContainsAny(string(t.Name), " \t\n") {
+			return fmt.Errorf("map %s: territory %q must be a single word", m.Name, t.Name)
+		}
+		if _, ok := m.adjacent[t.Name]; ok {
+			return fmt.Errorf("map %s: territory %s is defined twice", m.Name, t.Name)
+		}
+		if t.Region != "" && !regions[t.Region] {
+			return fmt.Errorf("map %s: territory %s is in unknown region %s", m.Name, t.Name, t.Region)
+		}
+		m.adjacent[t.Name] = []Location{}
+		m.regionOf[t.Name] = t.Region
+	}
+	for _, r := range m.Regions {
+		if len(m.regionTerritories(r.Name)) == 0 {
+			return fmt.Errorf("map %s: region %s has no territories", m.Name, r.Name)
+		}
+	}
+
+	for _, e := range m.Edges {
+		a, b := e[0], e[1]
+		for _, loc := range e {
+			if _, ok := m.adjacent[loc]; !ok {
+				return fmt.Errorf("map %s: edge %s-%s uses unknown territory %s", m.Name, a, b, loc)
+			}
+		}
+		if a == b {
+			return fmt.Errorf("map %s: territory %s can't border itself", m.Name, a)
+		}
+		if m.Adjacent(a, b) {
+			return fmt.Errorf("map %s: edge %s-%s is defined twice", m.Name, a, b)
+		}
+		m.adjacent[a] = append(m.adjacent[a], b)
+		m.adjacent[b] = append(m.adjacent[b], a)
+	}
+	for _, neighbors := range m.adjacent {
+		sort.Slice(neighbors, func(i, j int) bool {
+			return neighbors[i] < neighbors[j]
+		})
+	}
+
+	first := m.Territories[0].Name
+	for _, t := range m.Territories[1:] {
+		if m.Route(first, t.Name) == nil {
+			return fmt.Errorf("map %s: %s can't be reached from %s", m.Name, t.Name, first)
+		}
+	}
+	return nil
+}
+
+// Locations returns every territory in alphabetical order.
+func (m *Map) Locations() []Location {
+	locations := []Location{}
+	for loc := range m.adjacent {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i] < locations[j]
+	})
+	return locations
+}
+
+func (m *Map) Has(loc Location) bool {
+	_, ok := m.adjacent[loc]
+	return ok
+}
+
+func (m *Map) Neighbors(loc Location) []Location {
+	return append([]Location{}, m.adjacent[loc]...)
+}
+
+func (m *Map) Adjacent(a, b Location) bool {
+	for _, loc := range m.adjacent[a] {
+		if loc == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Route returns the shortest way from one territory to another, without
+// from itself, or nil when there is none.
+func (m *Map) Route(from, to Location) []Location {
+	if !m.Has(from) || !m.Has(to) || from == to {
+		return nil
+	}
+	prev := map[Location]Location{from: ""}
+	queue := []Location{from}
+	for len(queue) > 0 {
+		loc := queue[0]
+		queue = queue[1:]
+		for _, next := range m.adjacent[loc] {
+			if _, seen := prev[next]; seen {
+				continue
+			}
+			prev[next] = loc
+			if next == to {
+				route := []Location{}
+				for at := to; at != from; at = prev[at] {
+					route = append([]Location{at}, route...)
+				}
+				return route
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
+// Region returns the region loc belongs to, or "" if it has none.
+func (m *Map) Region(loc Location) string {
+	return m.regionOf[loc]
+}
+
+// HeldRegions returns the regions where p has units in every territory.
+func (m *Map) HeldRegions(p Player) []Region {
+	occupied := map[Location]bool{}
+	for _, unit := range p.Units {
+		occupied[unit.Location] = true
+	}
+	held := []Region{}
+	for _, r := range m.Regions {
+		all := true
+		for _, loc := range m.regionTerritories(r.Name) {
+			if !occupied[loc] {
+				all = false
+				break
+			}
+		}
+		if all {
+			held = append(held, r)
+		}
+	}
+	return held
+}
+
+func (m *Map) regionTerritories(region string) []Location {
+	locations := []Location{}
+	for _, t := range m.Territories {
+		if t.Region == region {
+			locations = append(locations, t.Name)
+		}
+	}
+	return locations
+}
+
+// Bonus is the sum of the bonuses of every region p holds.
+func (m *Map) Bonus(p Player) int {
+	bonus := 0
+	for _, r := range m.HeldRegions(p) {
+		bonus += r.Bonus
+	}
+	return bonus
+}
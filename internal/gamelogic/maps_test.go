@@ -0,0 +1,145 @@
+package gamelogic
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadMap(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		data          string
+		wantErr       bool
+		wantLocations []Location
+	}{
+		{
+			name:          "json",
+			file:          "islands.json",
+			data:          `{"name": "islands", "territories": [{"name": "north", "region": "isles"}, {"name": "south", "region": "isles"}, {"name": "reef"}], "edges": [["north", "south"], ["south", "reef"]], "regions": [{"name": "isles", "bonus": 2}]}`,
+			wantLocations: []Location{"north", "reef", "south"},
+		},
+		{
+			name:          "yaml",
+			file:          "islands.yaml",
+			data:          "name: islands\nterritories:\n  - name: north\n  - name: south\nedges:\n  - [north, south]\n",
+			wantLocations: []Location{"north", "south"},
+		},
+		{
+			name:          "yml",
+			file:          "islands.YML",
+			data:          "name: islands\nterritories:\n  - name: north\n  - name: south\nedges:\n  - [north, south]\n",
+			wantLocations: []Location{"north", "south"},
+		},
+		{
+			name:    "unsupported file",
+			file:    "islands.toml",
+			data:    `name = "islands"`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			file:    "islands.yaml",
+			data:    "name: [islands",
+			wantErr: true,
+		},
+		{
+			name:    "no name",
+			file:    "islands.json",
+			data:    `{"territories": [{"name": "north"}, {"name": "south"}], "edges": [["north", "south"]]}`,
+			wantErr: true,
+		},
+		{
+			name:    "single territory",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "territory of several words",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north cape"}, {"name": "south"}], "edges": [["north cape", "south"]]}`,
+			wantErr: true,
+		},
+		{
+			name:    "territory defined twice",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north"}, {"name": "north"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown region",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north", "region": "isles"}, {"name": "south"}], "edges": [["north", "south"]]}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty region",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north"}, {"name": "south"}], "edges": [["north", "south"]], "regions": [{"name": "isles", "bonus": 2}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative bonus",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north", "region": "isles"}, {"name": "south"}], "edges": [["north", "south"]], "regions": [{"name": "isles", "bonus": -1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "edge to an unknown territory",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north"}, {"name": "south"}], "edges": [["north", "south"], ["south", "atlantis"]]}`,
+			wantErr: true,
+		},
+		{
+			name:    "territory bordering itself",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north"}, {"name": "south"}], "edges": [["north", "south"], ["north", "north"]]}`,
+			wantErr: true,
+		},
+		{
+			name:    "edge defined twice",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north"}, {"name": "south"}], "edges": [["north", "south"], ["south", "north"]]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unreachable territory",
+			file:    "islands.json",
+			data:    `{"name": "islands", "territories": [{"name": "north"}, {"name": "south"}, {"name": "reef"}], "edges": [["north", "south"]]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			m, err := LoadMap(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadMap() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := m.Locations(); !slices.Equal(got, tt.wantLocations) {
+				t.Errorf("Locations() = %v, want %v", got, tt.wantLocations)
+			}
+			// Edges go both ways.
+			for _, e := range m.Edges {
+				if !m.Adjacent(e[0], e[1]) || !m.Adjacent(e[1], e[0]) {
+					t.Errorf("%s and %s don't border each other", e[0], e[1])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMapMissingFile(t *testing.T) {
+	if _, err := LoadMap(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loaded a map that doesn't exist")
+	}
+}
@@ -3,7 +3,9 @@ package gamelogic
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type MoveOutcome int
@@ -14,51 +16,107 @@ const (
 	MoveOutcomeMakeWar
 )
 
+// HandleMove reacts to a move. The moves of other players must be whole,
+// as World.ApplyMove returns them, not deltas.
 func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
-	defer fmt.Println("------------------------")
 	player := gs.GetPlayerSnap()
 
-	fmt.Println()
-	fmt.Println("==== Move Detected ====")
-	fmt.Printf("%s is moving %v unit(s) to %s\n", move.Player.Username, len(move.Units), move.ToLocation)
-	for _, unit := range move.Units {
-		fmt.Printf("* %v\n", unit.Rank)
-	}
-
 	if player.Username == move.Player.Username {
+		gs.mu.Lock()
+		gs.version = max(gs.version, move.Version)
+		gs.mu.Unlock()
+		move.Units = gs.applyOwnMove(move)
+		gs.emit(MoveDetected{Move: move, Outcome: MoveOutcomeSamePlayer})
 		return MoveOutcomeSamePlayer
 	}
 
-	overlappingLocation := getOverlappingLocation(player, move.Player)
-	if overlappingLocation != "" {
-		fmt.Printf("You have units in %s! You are at war with %s!\n", overlappingLocation, move.Player.Username)
+	if kind, ok := gs.PactWith(move.Player.Username); ok {
+		gs.emit(MoveDetected{Move: move, Outcome: MoveOutComeSafe, Pact: kind})
+		return MoveOutComeSafe
+	}
+	if contested := getOverlappingLocations(player, move.Player); len(contested) > 0 {
+		gs.emit(MoveDetected{Move: move, Outcome: MoveOutcomeMakeWar, Locations: contested})
 		return MoveOutcomeMakeWar
 	}
-	fmt.Printf("You are safe from %s's units.\n", move.Player.Username)
+	gs.emit(MoveDetected{Move: move, Outcome: MoveOutComeSafe})
 	return MoveOutComeSafe
 }
 
-func getOverlappingLocation(p1 Player, p2 Player) Location {
+// Intent is a move of the player as they send it to the server, planned on
+// the last move of theirs they heard back.
+func (gs *GameState) Intent(mv ArmyMove) MoveIntent {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return mv.Intent(gs.version)
+}
+
+// Rejection is a move the server refused, sent back to the mover with
+// their army as the server knows it.
+type Rejection struct {
+	Move   ArmyMove
+	Reason string
+	Army   Player
+}
+
+// HandleRejection undoes a move the server refused: the player's units go
+// back to where the server has them.
+func (gs *GameState) HandleRejection(r Rejection) {
+	gs.Restore(r.Army)
+	gs.emit(MoveRejected{Move: r.Move, Reason: r.Reason})
+}
+
+// applyOwnMove moves the player's surviving units once the move comes back
+// from the broker, and returns them. Outside of turn mode the units were
+// already moved by CommandMove, so this is a no-op.
+func (gs *GameState) applyOwnMove(move ArmyMove) []Unit {
+	moved := []Unit{}
+	for _, id := range move.MovedIDs() {
+		unit, ok := gs.GetUnit(id)
+		if !ok {
+			continue
+		}
+		unit.Location = move.ToLocation
+		gs.UpdateUnit(unit)
+		moved = append(moved, unit)
+	}
+	return moved
+}
+
+// getOverlappingLocations returns every location where both players have
+// units in alphabetical order, so every client fights the same battles in
+// the same order.
+func getOverlappingLocations(p1 Player, p2 Player) []Location {
+	occupied := map[Location]bool{}
 	for _, u1 := range p1.Units {
-		for _, u2 := range p2.Units {
-			if u1.Location == u2.Location {
-				return u1.Location
-			}
+		occupied[u1.Location] = true
+	}
+	contested := map[Location]bool{}
+	for _, u2 := range p2.Units {
+		if occupied[u2.Location] {
+			contested[u2.Location] = true
 		}
 	}
-	return ""
+	locations := []Location{}
+	for loc := range contested {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i] < locations[j]
+	})
+	return locations
 }
 
 func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
-	if gs.isPaused() {
+	if gs.IsPaused() {
 		return ArmyMove{}, errors.New("the game is paused, you can not move units")
 	}
 	if len(words) < 3 {
 		return ArmyMove{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
 	}
 	newLocation := Location(words[1])
-	locations := getAllLocations()
-	if _, ok := locations[newLocation]; !ok {
+	rules := gs.Rules()
+	board := rules.Map
+	if !board.Has(newLocation) {
 		return ArmyMove{}, fmt.Errorf("error: %s is not a valid location", newLocation)
 	}
 	unitIDs := []int{}
@@ -77,16 +135,51 @@ func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
 		if !ok {
 			return ArmyMove{}, fmt.Errorf("error: unit with ID %v not found", unitID)
 		}
+		t, _ := rules.Catalog.Lookup(unit.Rank)
+		route := board.Route(unit.Location, newLocation)
+		if unit.Location != newLocation && route == nil {
+			return ArmyMove{}, fmt.Errorf("error: unit %d in %s can't reach %s", unitID, unit.Location, newLocation)
+		}
+		if len(route) > max(t.Movement, 1) {
+			return ArmyMove{}, fmt.Errorf(
+				"error: %s %d can cross %d border(s) per move, the way from %s to %s is %s",
+				unit.Rank, unitID, max(t.Movement, 1), unit.Location, newLocation,
+				describeRoute(append([]Location{unit.Location}, route...)),
+			)
+		}
 		unit.Location = newLocation
-		gs.UpdateUnit(unit)
 		newUnits = append(newUnits, unit)
 	}
 
+	if gs.IsTurnBased() {
+		return gs.queueMove(newUnits, newLocation)
+	}
+
+	for _, unit := range newUnits {
+		gs.UpdateUnit(unit)
+	}
+
 	mv := ArmyMove{
 		ToLocation: newLocation,
 		Units:      newUnits,
 		Player:     gs.GetPlayerSnap(),
 	}
-	fmt.Printf("Moved %v units to %s\n", len(mv.Units), mv.ToLocation)
+	gs.emit(UnitsMoved{Move: mv})
 	return mv, nil
 }
+
+func describeLocations(locations []Location) string {
+	parts := []string{}
+	for _, loc := range locations {
+		parts = append(parts, string(loc))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeRoute(route []Location) string {
+	parts := []string{}
+	for _, loc := range route {
+		parts = append(parts, string(loc))
+	}
+	return strings.Join(parts, " -> ")
+}
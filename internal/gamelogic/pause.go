@@ -1,19 +1,24 @@
 package gamelogic
 
 import (
-	"fmt"
-
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
 )
 
+// HandlePause ignores a state older than the latest one the player heard
+// of, e.g. the reply to a state request after a newer pause broadcast.
 func (gs *GameState) HandlePause(ps routing.PlayingState) {
-	defer fmt.Println("------------------------")
-	fmt.Println()
+	gs.mu.Lock()
+	if ps.Seq < gs.pauseSeq {
+		gs.mu.Unlock()
+		return
+	}
+	gs.pauseSeq = ps.Seq
+	gs.mu.Unlock()
+
 	if ps.IsPaused {
-		fmt.Println("==== Pause Detected ====")
 		gs.pauseGame()
 	} else {
-		fmt.Println("==== Resume Detected ====")
 		gs.resumeGame()
 	}
+	gs.emit(PauseChanged{Paused: ps.IsPaused})
 }
@@ -0,0 +1,404 @@
+package gamelogic
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const separator = "------------------------"
+
+// TextRenderer prints events as the classic console output of the client.
+type TextRenderer struct {
+	w io.Writer
+}
+
+func NewTextRenderer(w io.Writer) *TextRenderer {
+	return &TextRenderer{w: w}
+}
+
+func (r *TextRenderer) Notify(e Event) {
+	switch e := e.(type) {
+	case MoveDetected:
+		r.renderMoveDetected(e)
+	case UnitsMoved:
+		fmt.Fprintf(r.w, "Moved %v units to %s\n", len(e.Move.Units), e.Move.ToLocation)
+	case MoveRejected:
+		fmt.Fprintf(r.w, "The server refused your move to %s: %s\n", e.Move.ToLocation, e.Reason)
+	case MoveQueued:
+		fmt.Fprintf(r.w, "Queued a move of %v units to %s, submit your orders to end your turn\n", len(e.Move.Units), e.Move.ToLocation)
+	case OrdersSubmitted:
+		fmt.Fprintf(r.w, "Submitted %d order(s) for round %d\n", e.Moves, e.Round)
+	case RoundChanged:
+		r.renderRoundChanged(e)
+	case WarDeclared:
+		fmt.Fprintln(r.w)
+		fmt.Fprintln(r.w, "==== War Declared ====")
+		fmt.Fprintf(r.w, "%s has declared war on %s!\n", e.Attacker, e.Defender)
+	case WarResolved:
+		r.renderWarResolved(e)
+	case UnitsLost:
+		// Reported together with the WarResolved event that follows.
+	case UnitSpawned:
+		fmt.Fprintf(r.w, "Spawned a(n) %s in %s with id %v\n", e.Unit.Rank, e.Unit.Location, e.Unit.ID)
+	case PauseChanged:
+		fmt.Fprintln(r.w)
+		if e.Paused {
+			fmt.Fprintln(r.w, "==== Pause Detected ====")
+		} else {
+			fmt.Fprintln(r.w, "==== Resume Detected ====")
+		}
+		fmt.Fprintln(r.w, separator)
+	case StatusReported:
+		r.renderStatus(e)
+	case Journaled:
+		// Only shown on request, through the history command.
+	case HistoryReported:
+		r.renderHistory(e)
+	case ArmyReported:
+		r.renderArmy(e)
+	case MapReported:
+		r.renderMap(e)
+	case CatalogReported:
+		r.renderCatalog(e)
+	case TreasuryChanged:
+		fmt.Fprintf(r.w, "Round %d paid you %d gold, you now have %d\n", e.Treasury.Round, e.Treasury.Income, e.Treasury.Gold)
+	case TreasuryReported:
+		r.renderTreasury(e)
+	case EconomyReported:
+		r.renderEconomy(e)
+	case DiplomacyChanged:
+		fmt.Fprintln(r.w)
+		fmt.Fprintln(r.w, "==== Diplomacy Changed ====")
+		r.renderDiplomacy(e.State, e.Player)
+		fmt.Fprintln(r.w, separator)
+	case DiplomacyReported:
+		r.renderDiplomacy(e.State, e.Player)
+	case ChatReceived:
+		r.renderChat(e)
+	case GameEnded:
+		r.renderGameOver(e)
+	}
+}
+
+func (r *TextRenderer) renderMoveDetected(e MoveDetected) {
+	fmt.Fprintln(r.w)
+	fmt.Fprintln(r.w, "==== Move Detected ====")
+	fmt.Fprintf(r.w, "%s is moving %v unit(s) to %s\n", e.Move.Player.Username, len(e.Move.Units), e.Move.ToLocation)
+	for _, unit := range e.Move.Units {
+		fmt.Fprintf(r.w, "* %v\n", unit.Rank)
+	}
+	switch e.Outcome {
+	case MoveOutcomeMakeWar:
+		fmt.Fprintf(r.w, "You have units in %s! You are at war with %s!\n", describeLocations(e.Locations), e.Move.Player.Username)
+	case MoveOutComeSafe:
+		if e.Pact != "" {
+			fmt.Fprintf(r.w, "You have a(n) %s with %s, their units are no threat.\n", e.Pact, e.Move.Player.Username)
+			break
+		}
+		fmt.Fprintf(r.w, "You are safe from %s's units.\n", e.Move.Player.Username)
+	}
+	fmt.Fprintln(r.w, separator)
+}
+
+func (r *TextRenderer) renderRoundChanged(e RoundChanged) {
+	fmt.Fprintln(r.w)
+	switch {
+	case !e.State.TurnBased:
+		fmt.Fprintln(r.w, "==== Turn Mode Disabled ====")
+		fmt.Fprintln(r.w, "Moves are applied immediately again.")
+	case e.State.Open:
+		fmt.Fprintf(r.w, "==== Round %d Started ====\n", e.State.Round)
+		fmt.Fprintf(r.w, "Queue your moves and submit them before %s.\n", e.State.Deadline.Format(time.TimeOnly))
+	default:
+		fmt.Fprintf(r.w, "==== Round %d Ended ====\n", e.State.Round)
+		fmt.Fprintln(r.w, "Orders are being resolved.")
+	}
+	fmt.Fprintln(r.w, separator)
+}
+
+func (r *TextRenderer) renderWarResolved(e WarResolved) {
+	defer fmt.Fprintln(r.w, separator)
+
+	switch e.Outcome {
+	case WarOutcomeNotInvolved:
+		fmt.Fprintf(r.w, "%s, you are not involved in this war.\n", e.Player)
+		return
+	case WarOutcomeNoUnits:
+		fmt.Fprintf(r.w, "Error! No units are in the same location. No war will be fought.\n")
+		return
+	}
+
+	for _, result := range e.Results {
+		if len(e.Results) > 1 {
+			fmt.Fprintf(r.w, "== Battle of %s ==\n", result.Battle.Location)
+		}
+		r.renderBattle(result)
+	}
+	if len(e.Results) > 1 {
+		won, lost := 0, 0
+		for _, result := range e.Results {
+			switch result.Outcome {
+			case WarOutcomeYouWon:
+				won++
+			case WarOutcomeOpponentWon:
+				lost++
+			}
+		}
+		fmt.Fprintf(r.w, "You won %d and lost %d of %d battles against %s.\n", won, lost, len(e.Results), e.Defender)
+	}
+}
+
+func (r *TextRenderer) renderBattle(result BattleResult) {
+	b := result.Battle
+	fmt.Fprintf(r.w, "%s's units:\n", b.Attacker)
+	for _, unit := range b.AttackerUnits {
+		fmt.Fprintf(r.w, "  * %v\n", unit.Rank)
+	}
+	fmt.Fprintf(r.w, "%s's units:\n", b.Defender)
+	for _, unit := range b.DefenderUnits {
+		fmt.Fprintf(r.w, "  * %v\n", unit.Rank)
+	}
+	for _, ally := range b.Allies {
+		fmt.Fprintf(r.w, "%s's units, allied with %s:\n", ally.Username, b.Defender)
+		for _, unit := range ally.Units {
+			fmt.Fprintf(r.w, "  * %v\n", unit.Rank)
+		}
+	}
+	fmt.Fprintf(r.w, "Attacker has a power level of %v\n", b.AttackerPower)
+	fmt.Fprintf(r.w, "Defender has a power level of %v\n", b.DefenderPower)
+	for i, round := range b.Rounds {
+		fmt.Fprintf(r.w, "Round %d: %s rolled %s against %s, ", i+1, b.Attacker, describeRolls(round.AttackerRolls), describeRolls(round.DefenderRolls))
+		fmt.Fprintf(r.w, "%s lost %s, %s lost %s\n", b.Attacker, describeUnits(round.AttackerLosses), b.Defender, describeUnits(round.DefenderLosses))
+	}
+
+	winner, _, _ := b.Result()
+	switch result.Outcome {
+	case WarOutcomeDraw:
+		fmt.Fprintln(r.w, "The war ended in a draw!")
+	case WarOutcomeOpponentWon:
+		fmt.Fprintf(r.w, "%s has won the war!\n", winner)
+		fmt.Fprintln(r.w, "You have lost the war!")
+	case WarOutcomeYouWon:
+		fmt.Fprintf(r.w, "%s has won the war!\n", winner)
+	}
+	if result.Outcome != WarOutcomeYouWon {
+		fmt.Fprintf(r.w, "Your units in %s have been killed.\n", b.Location)
+	} else if len(result.Lost) > 0 {
+		fmt.Fprintf(r.w, "Victory cost you %s in %s.\n", describeUnits(result.Lost), b.Location)
+	}
+}
+
+func describeRolls(rolls []int) string {
+	parts := []string{}
+	for _, roll := range rolls {
+		parts = append(parts, strconv.Itoa(roll))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *TextRenderer) renderStatus(e StatusReported) {
+	if e.Paused {
+		fmt.Fprintln(r.w, "The game is paused.")
+		return
+	}
+	fmt.Fprintln(r.w, "The game is not paused.")
+
+	if e.Turn.TurnBased {
+		if !e.Turn.Open {
+			fmt.Fprintf(r.w, "Round %d is being resolved.\n", e.Turn.Round)
+		} else {
+			fmt.Fprintf(r.w, "Round %d is open until %s, you have queued %d order(s)", e.Turn.Round, e.Turn.Deadline.Format(time.TimeOnly), e.Turn.Orders)
+			if e.Turn.Submitted {
+				fmt.Fprint(r.w, " and submitted them")
+			}
+			fmt.Fprintln(r.w, ".")
+		}
+	}
+
+	fmt.Fprintf(r.w, "You are %s, and you have %d units.\n", e.Player.Username, len(e.Player.Units))
+	for _, unit := range e.Player.Units {
+		fmt.Fprintf(r.w, "* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+	}
+	for _, region := range e.Regions {
+		fmt.Fprintf(r.w, "You hold %s (bonus %d).\n", region.Name, region.Bonus)
+	}
+}
+
+func (r *TextRenderer) renderTreasury(e TreasuryReported) {
+	if e.Free {
+		fmt.Fprintln(r.w, "Spawning is free in this game.")
+		return
+	}
+	fmt.Fprintf(r.w, "You have %d gold.\n", e.Treasury.Gold)
+	if e.Treasury.Round > 0 {
+		fmt.Fprintf(r.w, "Round %d paid you %d gold.\n", e.Treasury.Round, e.Treasury.Income)
+	}
+}
+
+func (r *TextRenderer) renderEconomy(e EconomyReported) {
+	fmt.Fprintf(r.w, "You have %d gold and will earn %d at the end of the round:\n", e.Gold, e.Income.Total)
+	fmt.Fprintf(r.w, "* %d gold for each of the %d location(s) you hold", e.Income.LocationIncome, len(e.Income.Locations))
+	if len(e.Income.Locations) > 0 {
+		fmt.Fprintf(r.w, ": %s", describeLocations(e.Income.Locations))
+	}
+	fmt.Fprintln(r.w)
+	for _, region := range e.Income.Regions {
+		fmt.Fprintf(r.w, "* %d gold for holding %s\n", region.Bonus, region.Name)
+	}
+	fmt.Fprintln(r.w, "Unit costs:")
+	for _, t := range e.Catalog.Units {
+		fmt.Fprintf(r.w, "* %s: %d gold\n", t.Rank, t.Cost)
+	}
+}
+
+func (r *TextRenderer) renderChat(e ChatReceived) {
+	msg := e.Message
+	switch msg.Channel {
+	case routing.ChatTeam:
+		fmt.Fprintf(r.w, "[team %s] %s: %s\n", msg.Team, msg.Username, msg.Text)
+	case routing.ChatDirect:
+		switch e.Player {
+		case msg.Username:
+			fmt.Fprintf(r.w, "[to %s] %s\n", msg.To, msg.Text)
+		default:
+			fmt.Fprintf(r.w, "[from %s] %s\n", msg.Username, msg.Text)
+		}
+	default:
+		fmt.Fprintf(r.w, "%s: %s\n", msg.Username, msg.Text)
+	}
+}
+
+func (r *TextRenderer) renderGameOver(e GameEnded) {
+	fmt.Fprintln(r.w)
+	fmt.Fprintln(r.w, "==== Game Over ====")
+	winner := strings.Join(e.Over.Winners, ", ")
+	if e.Over.Team != "" {
+		winner = fmt.Sprintf("Team %s (%s)", e.Over.Team, winner)
+	}
+	fmt.Fprintf(r.w, "%s won: %s.\n", winner, e.Over.Reason)
+	if slices.Contains(e.Over.Winners, e.Player) {
+		fmt.Fprintln(r.w, "Congratulations, you won!")
+	}
+	fmt.Fprintln(r.w, "Scoreboard:")
+	for i, score := range e.Over.Scoreboard {
+		name := score.Username
+		if score.Team != "" {
+			name += " (" + score.Team + ")"
+		}
+		fmt.Fprintf(r.w, "%d. %s: %d territories, %d units, power %d, %d gold\n", i+1, name, score.Territories, score.Units, score.Power, score.Gold)
+	}
+	fmt.Fprintln(r.w, separator)
+}
+
+// renderDiplomacy lists the pacts and proposals of player first, then the
+// pacts between the other players.
+func (r *TextRenderer) renderDiplomacy(state routing.DiplomacyState, player string) {
+	if team := state.Teams[player]; team != "" {
+		mates := []string{}
+		for username, t := range state.Teams {
+			if t == team && username != player {
+				mates = append(mates, username)
+			}
+		}
+		sort.Strings(mates)
+		if len(mates) == 0 {
+			fmt.Fprintf(r.w, "* You are alone on team %s.\n", team)
+		} else {
+			fmt.Fprintf(r.w, "* You are on team %s with %s.\n", team, strings.Join(mates, ", "))
+		}
+	}
+	if len(state.Pacts) == 0 && len(state.Proposals) == 0 {
+		if len(state.Teams) == 0 {
+			fmt.Fprintln(r.w, "There are no pacts in this game.")
+		}
+		return
+	}
+	for _, pact := range state.Pacts {
+		switch player {
+		case pact.Players[0]:
+			fmt.Fprintf(r.w, "* You have a(n) %s with %s.\n", pact.Kind, pact.Players[1])
+		case pact.Players[1]:
+			fmt.Fprintf(r.w, "* You have a(n) %s with %s.\n", pact.Kind, pact.Players[0])
+		}
+	}
+	for _, p := range state.Proposals {
+		switch player {
+		case p.To:
+			fmt.Fprintf(r.w, "* %s proposes a(n) %s, answer with: accept %s\n", p.From, p.Kind, p.From)
+		case p.From:
+			fmt.Fprintf(r.w, "* You proposed a(n) %s to %s.\n", p.Kind, p.To)
+		}
+	}
+	for _, pact := range state.Pacts {
+		if pact.Players[0] != player && pact.Players[1] != player {
+			fmt.Fprintf(r.w, "* %s and %s have a(n) %s.\n", pact.Players[0], pact.Players[1], pact.Kind)
+		}
+	}
+}
+
+func (r *TextRenderer) renderCatalog(e CatalogReported) {
+	fmt.Fprintf(r.w, "Units of %s:\n", e.Catalog.Name)
+	for _, t := range e.Catalog.Units {
+		fmt.Fprintf(r.w, "* %s: attack %d, defense %d, movement %d, cost %d", t.Rank, t.Attack, t.Defense, t.Movement, t.Cost)
+		if len(t.Special) > 0 {
+			fmt.Fprintf(r.w, ", %s", strings.Join(t.Special, ", "))
+		}
+		fmt.Fprintln(r.w)
+	}
+}
+
+func (r *TextRenderer) renderMap(e MapReported) {
+	fmt.Fprintf(r.w, "Map %s:\n", e.Map.Name)
+	for _, loc := range e.Map.Locations() {
+		units := 0
+		for _, unit := range e.Player.Units {
+			if unit.Location == loc {
+				units++
+			}
+		}
+		fmt.Fprintf(r.w, "* %s", loc)
+		if region := e.Map.Region(loc); region != "" {
+			fmt.Fprintf(r.w, " [%s]", region)
+		}
+		if units > 0 {
+			fmt.Fprintf(r.w, ", %d of your units", units)
+		}
+		fmt.Fprintf(r.w, ", borders %s\n", describeLocations(e.Map.Neighbors(loc)))
+	}
+	for _, region := range e.Map.Regions {
+		fmt.Fprintf(r.w, "Holding all of %s is worth a bonus of %d.\n", region.Name, region.Bonus)
+	}
+}
+
+func (r *TextRenderer) renderHistory(e HistoryReported) {
+	switch {
+	case len(e.Entries) == 0 && e.UnitID != 0:
+		fmt.Fprintf(r.w, "Unit %d never appeared in your journal.\n", e.UnitID)
+	case len(e.Entries) == 0:
+		fmt.Fprintln(r.w, "Your journal is empty.")
+	}
+	for _, entry := range e.Entries {
+		fmt.Fprintln(r.w, entry)
+	}
+}
+
+func (r *TextRenderer) renderArmy(e ArmyReported) {
+	units := []Unit{}
+	for _, unit := range e.Army.Player.Units {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	fmt.Fprintf(r.w, "After entry #%d you had %d units.\n", e.Army.Seq, len(units))
+	for _, unit := range units {
+		fmt.Fprintf(r.w, "* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+	}
+}
@@ -0,0 +1,90 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Rules are the data a game is played with. The server sends them to every
+// player joining, so all participants move and fight by the same rules.
+type Rules struct {
+	Map     *Map
+	Catalog *Catalog
+	Economy Economy
+	Combat  string `json:",omitempty"`
+	Victory Victory
+	// Fog hides the enemy units that are not in or next to a player's
+	// locations. Moves go through the server, which only tells every
+	// player what they can see.
+	Fog bool `json:",omitempty"`
+}
+
+// Resolver returns the combat resolver of the rules. Rules are validated
+// when loaded, so an unknown combat rule falls back to strength.
+func (r Rules) Resolver() CombatResolver {
+	resolver, err := NewCombatResolver(r.Combat)
+	if err != nil {
+		return StrengthResolver{}
+	}
+	return resolver
+}
+
+func ClassicRules() Rules {
+	return Rules{Map: ClassicMap(), Catalog: ClassicCatalog(), Economy: ClassicEconomy()}
+}
+
+// LoadRules reads a map and a unit catalog from files. An empty path keeps
+// the classic map or catalog.
+func LoadRules(mapFile, catalogFile string) (Rules, error) {
+	r := ClassicRules()
+	if mapFile != "" {
+		m, err := LoadMap(mapFile)
+		if err != nil {
+			return Rules{}, err
+		}
+		r.Map = m
+	}
+	if catalogFile != "" {
+		c, err := LoadCatalog(catalogFile)
+		if err != nil {
+			return Rules{}, err
+		}
+		r.Catalog = c
+	}
+	return r, nil
+}
+
+// ParseRules decodes rules sent by the server. Anything missing, e.g. from
+// a server that predates unit catalogs, is taken from the classic rules.
+// The economy can't be told apart from a missing one when it is all zero,
+// so it is replaced too.
+func ParseRules(data []byte) (Rules, error) {
+	r := Rules{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &r); err != nil {
+			return Rules{}, fmt.Errorf("couldn't parse rules: %v", err)
+		}
+	}
+	if r.Map == nil {
+		r.Map = ClassicMap()
+	} else if err := r.Map.Validate(); err != nil {
+		return Rules{}, err
+	}
+	if r.Catalog == nil {
+		r.Catalog = ClassicCatalog()
+	} else if err := r.Catalog.Validate(); err != nil {
+		return Rules{}, err
+	}
+	if r.Economy == (Economy{}) {
+		r.Economy = ClassicEconomy()
+	} else if err := r.Economy.Validate(); err != nil {
+		return Rules{}, err
+	}
+	if _, err := NewCombatResolver(r.Combat); err != nil {
+		return Rules{}, err
+	}
+	if err := r.Victory.Validate(); err != nil {
+		return Rules{}, err
+	}
+	return r, nil
+}
@@ -11,24 +11,33 @@ func (gs *GameState) CommandSpawn(words []string) error {
 	}
 
 	locationName := words[1]
-	locations := getAllLocations()
-	if _, ok := locations[Location(locationName)]; !ok {
+	if !gs.Map().Has(Location(locationName)) {
 		return fmt.Errorf("error: %s is not a valid location", locationName)
 	}
 
 	rank := words[2]
-	units := getAllRanks()
-	if _, ok := units[UnitRank(rank)]; !ok {
+	if _, ok := gs.Catalog().Lookup(UnitRank(rank)); !ok {
 		return fmt.Errorf("error: %s is not a valid unit", rank)
 	}
 
-	id := len(gs.getUnitsSnap()) + 1
-	gs.addUnit(Unit{
+	id := gs.nextUnitID()
+	unit := Unit{
 		ID:       id,
 		Rank:     UnitRank(rank),
 		Location: Location(locationName),
-	})
+	}
+	gs.mu.RLock()
+	bank := gs.bank
+	gs.mu.RUnlock()
+	if bank != nil {
+		t, err := bank.Spend(unit)
+		if err != nil {
+			return err
+		}
+		gs.SetTreasury(t)
+	}
+	gs.addUnit(unit)
 
-	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, id)
+	gs.emit(UnitSpawned{Unit: unit})
 	return nil
 }
@@ -0,0 +1,152 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+type turnState struct {
+	enabled   bool
+	round     int
+	open      bool
+	deadline  time.Time
+	submitted bool
+	orders    []ArmyMove
+	pending   map[int]Unit
+}
+
+func (gs *GameState) HandleRound(rs routing.RoundState) {
+	gs.mu.Lock()
+	gs.setRound(rs)
+	gs.mu.Unlock()
+
+	gs.emit(RoundChanged{State: rs})
+}
+
+// SyncRound applies the round state a player fetched on joining, unless a
+// round announcement already arrived, which is newer.
+func (gs *GameState) SyncRound(rs routing.RoundState) {
+	gs.mu.Lock()
+	if gs.heardRound || !rs.TurnBased {
+		gs.mu.Unlock()
+		return
+	}
+	gs.setRound(rs)
+	gs.mu.Unlock()
+
+	gs.emit(RoundChanged{State: rs})
+}
+
+// setRound needs gs.mu to be held.
+func (gs *GameState) setRound(rs routing.RoundState) {
+	gs.heardRound = true
+	if rs.TurnBased {
+		gs.turn = turnState{
+			enabled:  true,
+			round:    rs.Round,
+			open:     rs.Open,
+			deadline: rs.Deadline,
+			orders:   []ArmyMove{},
+			pending:  map[int]Unit{},
+		}
+	} else {
+		gs.turn = turnState{}
+	}
+}
+
+func (gs *GameState) IsTurnBased() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.turn.enabled
+}
+
+// queueMove records a move for the current round without touching the
+// player's units. The returned move carries a snapshot of the player as it
+// will look once all orders queued so far have been applied.
+func (gs *GameState) queueMove(units []Unit, to Location) (ArmyMove, error) {
+	gs.mu.Lock()
+	if !gs.turn.open {
+		gs.mu.Unlock()
+		return ArmyMove{}, errors.New("no round is open, wait for the next round to start")
+	}
+	if gs.turn.submitted {
+		gs.mu.Unlock()
+		return ArmyMove{}, fmt.Errorf("you already submitted your orders for round %d", gs.turn.round)
+	}
+
+	for _, unit := range units {
+		gs.turn.pending[unit.ID] = unit
+	}
+	projected := map[int]Unit{}
+	for k, v := range gs.Player.Units {
+		projected[k] = v
+	}
+	for k, v := range gs.turn.pending {
+		projected[k] = v
+	}
+
+	mv := ArmyMove{
+		ToLocation: to,
+		Units:      units,
+		Player: Player{
+			Username: gs.Player.Username,
+			Units:    projected,
+		},
+	}
+	gs.turn.orders = append(gs.turn.orders, mv)
+	round := gs.turn.round
+	gs.mu.Unlock()
+
+	gs.emit(MoveQueued{Move: mv, Round: round})
+	return mv, nil
+}
+
+func (gs *GameState) CommandSubmit() (OrderSet, error) {
+	orders, err := gs.submitOrders()
+	if err != nil {
+		return OrderSet{}, err
+	}
+	gs.emit(OrdersSubmitted{Round: orders.Round, Moves: len(orders.Moves)})
+	return orders, nil
+}
+
+func (gs *GameState) submitOrders() (OrderSet, error) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if !gs.turn.enabled {
+		return OrderSet{}, errors.New("the game is not in turn mode, moves are applied immediately")
+	}
+	if !gs.turn.open {
+		return OrderSet{}, errors.New("no round is open, wait for the next round to start")
+	}
+	if gs.turn.submitted {
+		return OrderSet{}, fmt.Errorf("you already submitted your orders for round %d", gs.turn.round)
+	}
+	gs.turn.submitted = true
+
+	orders := []MoveIntent{}
+	for _, mv := range gs.turn.orders {
+		orders = append(orders, mv.Intent(gs.version))
+	}
+	return OrderSet{
+		Username: gs.Player.Username,
+		Round:    gs.turn.round,
+		Moves:    orders,
+	}, nil
+}
+
+func (gs *GameState) Turn() TurnStatus {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return TurnStatus{
+		TurnBased: gs.turn.enabled,
+		Round:     gs.turn.round,
+		Open:      gs.turn.open,
+		Deadline:  gs.turn.deadline,
+		Orders:    len(gs.turn.orders),
+		Submitted: gs.turn.submitted,
+	}
+}
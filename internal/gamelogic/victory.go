@@ -0,0 +1,167 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Victory is how a game is won by a side: a team, or a player on no team.
+// Conditions left at zero are off, and a game without any never ends on its
+// own.
+type Victory struct {
+	// Territories is how many locations a side must hold together, for
+	// HoldRounds rounds in a row.
+	Territories int `json:",omitempty"`
+	HoldRounds  int `json:",omitempty"`
+	// Elimination ends the game when a single side has units left.
+	Elimination bool `json:",omitempty"`
+	// RoundLimit ends the game after this many rounds, won by the side
+	// with the best score.
+	RoundLimit int `json:",omitempty"`
+}
+
+func (v Victory) Validate() error {
+	if v.Territories < 0 || v.HoldRounds < 0 || v.RoundLimit < 0 {
+		return errors.New("territories and rounds to win can't be negative")
+	}
+	return nil
+}
+
+func (v Victory) Enabled() bool {
+	return v.Territories > 0 || v.Elimination || v.RoundLimit > 0
+}
+
+// Conditions describes every way the game can be won.
+func (v Victory) Conditions() []string {
+	conditions := []string{}
+	if v.Territories > 0 {
+		conditions = append(conditions, fmt.Sprintf("hold %d territories for %d round(s)", v.Territories, max(v.HoldRounds, 1)))
+	}
+	if v.Elimination {
+		conditions = append(conditions, "be the last side with units")
+	}
+	if v.RoundLimit > 0 {
+		conditions = append(conditions, fmt.Sprintf("have the best score after %d round(s)", v.RoundLimit))
+	}
+	return conditions
+}
+
+// Standing is how a side is doing: the locations its players hold together
+// and how many units they have left.
+type Standing struct {
+	Team        string
+	Players     []string
+	Territories int
+	Units       int
+	Power       int
+}
+
+// Beats compares the scores of two sides: territories first, then units,
+// then power.
+func (s Standing) Beats(o Standing) bool {
+	if s.Territories != o.Territories {
+		return s.Territories > o.Territories
+	}
+	if s.Units != o.Units {
+		return s.Units > o.Units
+	}
+	return s.Power > o.Power
+}
+
+// Side names the side of a standing, the team or its only player.
+func (s Standing) Side() string {
+	if s.Team != "" {
+		return s.Team
+	}
+	return s.Players[0]
+}
+
+// Standings groups the players of a game by side, in name order.
+func (r Rules) Standings(w *World, players []string, teams map[string]string) []Standing {
+	bySide := map[string]*Standing{}
+	held := map[string]map[Location]bool{}
+	for _, username := range players {
+		st := Standing{Team: teams[username], Players: []string{username}}
+		side := st.Side()
+		if existing, ok := bySide[side]; ok {
+			existing.Players = append(existing.Players, username)
+		} else {
+			bySide[side] = &st
+			held[side] = map[Location]bool{}
+		}
+		p, _ := w.Player(username)
+		units := []Unit{}
+		for _, unit := range p.Units {
+			held[side][unit.Location] = true
+			units = append(units, unit)
+		}
+		bySide[side].Units += len(units)
+		bySide[side].Power += r.Catalog.Attack(units)
+	}
+	standings := []Standing{}
+	for side, st := range bySide {
+		st.Territories = len(held[side])
+		sort.Strings(st.Players)
+		standings = append(standings, *st)
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		return standings[i].Side() < standings[j].Side()
+	})
+	return standings
+}
+
+// Scoreboard puts the winners first, then ranks the players with Beats, by
+// the standing of their side and then by their own. A side can win without
+// the best score, e.g. by holding its territories longest.
+func (r Rules) Scoreboard(w *World, players []string, teams map[string]string, winners []string) []routing.Score {
+	sides := map[string]Standing{}
+	for _, st := range r.Standings(w, players, teams) {
+		sides[st.Side()] = st
+	}
+	type row struct {
+		side, own Standing
+		score     routing.Score
+	}
+	rows := []row{}
+	for _, username := range players {
+		own := r.Standings(w, []string{username}, nil)[0]
+		side := Standing{Team: teams[username], Players: []string{username}}.Side()
+		rows = append(rows, row{
+			side: sides[side],
+			own:  own,
+			score: routing.Score{
+				Username:    username,
+				Team:        teams[username],
+				Territories: own.Territories,
+				Units:       own.Units,
+				Power:       own.Power,
+			},
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if aWon, bWon := slices.Contains(winners, a.score.Username), slices.Contains(winners, b.score.Username); aWon != bWon {
+			return aWon
+		}
+		if a.side.Beats(b.side) || b.side.Beats(a.side) {
+			return a.side.Beats(b.side)
+		}
+		if a.own.Beats(b.own) || b.own.Beats(a.own) {
+			return a.own.Beats(b.own)
+		}
+		return a.score.Username < b.score.Username
+	})
+	scores := []routing.Score{}
+	for _, ranked := range rows {
+		scores = append(scores, ranked.score)
+	}
+	return scores
+}
+
+func (gs *GameState) HandleGameOver(over routing.GameOver) {
+	gs.emit(GameEnded{Over: over, Player: gs.GetUsername()})
+}
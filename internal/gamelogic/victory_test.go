@@ -0,0 +1,42 @@
+package gamelogic
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScoreboardPutsWinnersFirst(t *testing.T) {
+	rules := ClassicRules()
+	w := NewWorld()
+	w.SetRules(rules)
+	// alice holds the most, but bob won, e.g. by holding his territories
+	// for longer.
+	for i, loc := range []Location{"europe", "asia", "africa"} {
+		w.ApplySpawn("alice", Unit{ID: i + 1, Rank: RankInfantry, Location: loc})
+	}
+	w.ApplySpawn("bob", Unit{ID: 1, Rank: RankInfantry, Location: "americas"})
+	w.ApplySpawn("carol", Unit{ID: 1, Rank: RankInfantry, Location: "australia"})
+	w.ApplySpawn("carol", Unit{ID: 2, Rank: RankInfantry, Location: "antarctica"})
+
+	tests := []struct {
+		name    string
+		winners []string
+		want    []string
+	}{
+		{"no winner", nil, []string{"alice", "carol", "bob"}},
+		{"winner without the best score", []string{"bob"}, []string{"bob", "alice", "carol"}},
+		{"tied winners", []string{"bob", "carol"}, []string{"carol", "bob", "alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := rules.Scoreboard(w, []string{"alice", "bob", "carol"}, nil, tt.winners)
+			got := []string{}
+			for _, score := range scores {
+				got = append(got, score.Username)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Scoreboard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
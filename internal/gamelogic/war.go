@@ -2,6 +2,8 @@ package gamelogic
 
 import (
 	"fmt"
+	"math/rand"
+	"sort"
 )
 
 type WarOutcome int
@@ -14,92 +16,284 @@ const (
 	WarOutcomeDraw
 )
 
-func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner string, loser string) {
-	defer fmt.Println("------------------------")
-	fmt.Println()
-	fmt.Println("==== War Declared ====")
-	fmt.Printf("%s has declared war on %s!\n", rw.Attacker.Username, rw.Defender.Username)
+// BattleResult is how a single battle of a war went for the player.
+type BattleResult struct {
+	Battle  Battle
+	Outcome WarOutcome
+	Lost    []Unit
+}
 
+// DeclareWar is the war the player declares on an attacker who moved into
+// their locations, over the first location both have units in.
+func (gs *GameState) DeclareWar(attacker Player) WarDeclaration {
 	player := gs.GetPlayerSnap()
+	decl := WarDeclaration{Attacker: attacker.Username, Defender: player.Username}
+	if contested := getOverlappingLocations(player, attacker); len(contested) > 0 {
+		decl.Location = contested[0]
+	}
+	return decl
+}
+
+// HandleWar fights a battle in every location where both sides have units,
+// in alphabetical order, and returns one result per battle. Every side of a
+// battle, allies included, loses its own units. The outcome is the war as
+// a whole: won when the player won more battles than they lost, lost when
+// they lost more, a draw otherwise.
+func (gs *GameState) HandleWar(rw RecognitionOfWar) (WarOutcome, []BattleResult) {
+	gs.emit(WarDeclared{Attacker: rw.Attacker.Username, Defender: rw.Defender.Username})
+
+	player := gs.GetPlayerSnap()
+	resolved := WarResolved{
+		Player:   player.Username,
+		Attacker: rw.Attacker.Username,
+		Defender: rw.Defender.Username,
+		Results:  []BattleResult{},
+	}
+	battles := newBattles(rw, gs.Rules())
+	if len(battles) == 0 && (player.Username == rw.Attacker.Username || player.Username == rw.Defender.Username) {
+		resolved.Outcome = WarOutcomeNoUnits
+		gs.emit(resolved)
+		return resolved.Outcome, resolved.Results
+	}
+
+	involved, won, lost := false, 0, 0
+	for _, b := range battles {
+		result := BattleResult{Battle: b, Outcome: b.OutcomeFor(player.Username), Lost: []Unit{}}
+		switch result.Outcome {
+		case WarOutcomeNotInvolved:
+			resolved.Results = append(resolved.Results, result)
+			continue
+		case WarOutcomeYouWon:
+			won++
+		case WarOutcomeOpponentWon:
+			lost++
+		}
+		involved = true
+		if losses := b.Losses(player.Username); len(losses) > 0 {
+			winner, loser, _ := b.Result()
+			result.Lost = gs.removeUnits(losses, lossReason(result.Outcome, winner, loser, b.side(player.Username), b.Location))
+			gs.emit(UnitsLost{Location: b.Location, Units: result.Lost})
+		}
+		resolved.Results = append(resolved.Results, result)
+	}
+	switch {
+	case !involved:
+		resolved.Outcome = WarOutcomeNotInvolved
+	case won > lost:
+		resolved.Outcome = WarOutcomeYouWon
+	case lost > won:
+		resolved.Outcome = WarOutcomeOpponentWon
+	default:
+		resolved.Outcome = WarOutcomeDraw
+	}
+	gs.emit(resolved)
+	return resolved.Outcome, resolved.Results
+}
+
+// Battle is a war as it was fought: who fought with what, and what each
+// side lost. Rounds are only kept by resolvers that fight in rounds.
+type Battle struct {
+	Location       Location
+	Attacker       string
+	Defender       string
+	AttackerUnits  []Unit
+	DefenderUnits  []Unit
+	AttackerPower  int
+	DefenderPower  int
+	Allies         []Ally        `json:",omitempty"`
+	Rounds         []CombatRound `json:",omitempty"`
+	AttackerLosses []Unit
+	DefenderLosses []Unit
+	Winner         string
+	Loser          string
+	Draw           bool
+}
 
-	if player.Username == rw.Defender.Username {
-		fmt.Printf("%s, you published the war.\n", player.Username)
-		return WarOutcomeNotInvolved, "", ""
+// Ally is a player who defends alongside the defender of a battle. Allies
+// win and lose with the defender.
+type Ally struct {
+	Username string
+	Units    []Unit
+	Losses   []Unit
+}
+
+// defense is every unit fighting on the defender's side.
+func (b Battle) defense() []Unit {
+	units := append([]Unit{}, b.DefenderUnits...)
+	for _, ally := range b.Allies {
+		units = append(units, ally.Units...)
 	}
+	return units
+}
 
-	if player.Username != rw.Attacker.Username {
-		fmt.Printf("%s, you are not involved in this war.\n", player.Username)
-		return WarOutcomeNotInvolved, "", ""
+func (b *Battle) clearLosses() {
+	b.AttackerLosses, b.DefenderLosses = []Unit{}, []Unit{}
+	for i := range b.Allies {
+		b.Allies[i].Losses = []Unit{}
+	}
+}
+
+// loseDefense destroys every unit on the defender's side.
+func (b *Battle) loseDefense() {
+	b.DefenderLosses = b.DefenderUnits
+	for i := range b.Allies {
+		b.Allies[i].Losses = b.Allies[i].Units
+	}
+}
+
+func (b *Battle) lose(owner string, unit Unit) {
+	switch owner {
+	case b.Attacker:
+		b.AttackerLosses = append(b.AttackerLosses, unit)
+	case b.Defender:
+		b.DefenderLosses = append(b.DefenderLosses, unit)
+	}
+	for i := range b.Allies {
+		if b.Allies[i].Username == owner {
+			b.Allies[i].Losses = append(b.Allies[i].Losses, unit)
+		}
 	}
+}
 
-	overlappingLocation := getOverlappingLocation(rw.Attacker, rw.Defender)
-	if overlappingLocation == "" {
-		fmt.Printf("Error! No units are in the same location. No war will be fought.\n")
-		return WarOutcomeNoUnits, "", ""
+// newBattles pits the attacker's units against the defender's in every
+// contested location, in alphabetical order, and resolves the battles by
+// the combat rule of the game. The battles share one rng seeded by the war,
+// so everyone rolls the same dice for the same battle.
+func newBattles(rw RecognitionOfWar, r Rules) []Battle {
+	rng := rand.New(rand.NewSource(rw.Seed))
+	battles := []Battle{}
+	for _, loc := range getOverlappingLocations(rw.Attacker, rw.Defender) {
+		b := newBattle(rw, r.Catalog, loc)
+		battles = append(battles, r.Resolver().Resolve(b, r.Catalog, rng))
 	}
+	return battles
+}
 
-	attackerUnits := []Unit{}
-	defenderUnits := []Unit{}
+func newBattle(rw RecognitionOfWar, c *Catalog, overlappingLocation Location) Battle {
+	b := Battle{
+		Location:      overlappingLocation,
+		Attacker:      rw.Attacker.Username,
+		Defender:      rw.Defender.Username,
+		AttackerUnits: []Unit{},
+		DefenderUnits: []Unit{},
+	}
 	for _, unit := range rw.Attacker.Units {
 		if unit.Location == overlappingLocation {
-			attackerUnits = append(attackerUnits, unit)
+			b.AttackerUnits = append(b.AttackerUnits, unit)
 		}
 	}
 	for _, unit := range rw.Defender.Units {
 		if unit.Location == overlappingLocation {
-			defenderUnits = append(defenderUnits, unit)
+			b.DefenderUnits = append(b.DefenderUnits, unit)
 		}
 	}
+	sortUnits(b.AttackerUnits)
+	sortUnits(b.DefenderUnits)
 
-	fmt.Printf("%s's units:\n", rw.Attacker.Username)
-	for _, unit := range attackerUnits {
-		fmt.Printf("  * %v\n", unit.Rank)
-	}
-	fmt.Printf("%s's units:\n", rw.Defender.Username)
-	for _, unit := range defenderUnits {
-		fmt.Printf("  * %v\n", unit.Rank)
-	}
-	attackerPower := unitsToPowerLevel(attackerUnits)
-	defenderPower := unitsToPowerLevel(defenderUnits)
-	fmt.Printf("Attacker has a power level of %v\n", attackerPower)
-	fmt.Printf("Defender has a power level of %v\n", defenderPower)
-	if attackerPower > defenderPower {
-		fmt.Printf("%s has won the war!\n", rw.Attacker.Username)
-		if player.Username == rw.Defender.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Attacker.Username, rw.Defender.Username
+	allies := append([]Player{}, rw.Allies...)
+	sort.Slice(allies, func(i, j int) bool {
+		return allies[i].Username < allies[j].Username
+	})
+	for _, p := range allies {
+		if p.Username == b.Attacker || p.Username == b.Defender {
+			continue
 		}
-		return WarOutcomeYouWon, rw.Attacker.Username, rw.Defender.Username
-	} else if defenderPower > attackerPower {
-		fmt.Printf("%s has won the war!\n", rw.Defender.Username)
-		if player.Username == rw.Attacker.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Defender.Username, rw.Attacker.Username
+		ally := Ally{Username: p.Username, Units: []Unit{}}
+		for _, unit := range p.Units {
+			if unit.Location == overlappingLocation {
+				ally.Units = append(ally.Units, unit)
+			}
+		}
+		if len(ally.Units) > 0 {
+			sortUnits(ally.Units)
+			b.Allies = append(b.Allies, ally)
 		}
-		return WarOutcomeYouWon, rw.Defender.Username, rw.Attacker.Username
 	}
-	fmt.Println("The war ended in a draw!")
-	fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-	gs.removeUnitsInLocation(overlappingLocation)
-	return WarOutcomeDraw, rw.Attacker.Username, rw.Defender.Username
+
+	b.AttackerPower = c.Attack(b.AttackerUnits)
+	b.DefenderPower = c.Defense(b.defense())
+	return b
 }
 
-func unitsToPowerLevel(units []Unit) int {
-	power := 0
-	for _, unit := range units {
-		if unit.Rank == RankArtillery {
-			power += 10
-		}
-		if unit.Rank == RankCavalry {
-			power += 5
+// sortUnits sorts units by ID. Units come out of maps, and everyone must
+// fight them in the same order.
+func sortUnits(units []Unit) {
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+}
+
+// Summary is the line logged about the battle.
+func (b Battle) Summary() string {
+	winner, loser, draw := b.Result()
+	if draw {
+		return fmt.Sprintf("A war between %s and %s in %s resulted in a draw", winner, loser, b.Location)
+	}
+	return fmt.Sprintf("%s won a war against %s in %s", winner, loser, b.Location)
+}
+
+// OutcomeFor tells how the battle went for one of its sides. Allies share
+// the defender's outcome.
+func (b Battle) OutcomeFor(username string) WarOutcome {
+	_, loser, draw := b.Result()
+	side := b.side(username)
+	switch {
+	case side == "":
+		return WarOutcomeNotInvolved
+	case draw:
+		return WarOutcomeDraw
+	case loser == side:
+		return WarOutcomeOpponentWon
+	}
+	return WarOutcomeYouWon
+}
+
+// side is the attacker or defender username fights for, or empty when
+// they have no units in the battle.
+func (b Battle) side(username string) string {
+	if username == b.Attacker || username == b.Defender {
+		return username
+	}
+	for _, ally := range b.Allies {
+		if ally.Username == username {
+			return b.Defender
 		}
-		if unit.Rank == RankInfantry {
-			power += 1
+	}
+	return ""
+}
+
+// Losses returns the units a side of the battle lost.
+func (b Battle) Losses(username string) []Unit {
+	switch username {
+	case b.Attacker:
+		return b.AttackerLosses
+	case b.Defender:
+		return b.DefenderLosses
+	}
+	for _, ally := range b.Allies {
+		if ally.Username == username {
+			return ally.Losses
 		}
 	}
-	return power
+	return nil
+}
+
+// Result returns the winner and loser of the battle. On a draw winner is
+// the attacker.
+func (b Battle) Result() (winner string, loser string, draw bool) {
+	return b.Winner, b.Loser, b.Draw
+}
+
+func lossReason(outcome WarOutcome, winner, loser, player string, loc Location) string {
+	opponent := winner
+	if winner == player {
+		opponent = loser
+	}
+	switch outcome {
+	case WarOutcomeDraw:
+		return fmt.Sprintf("war against %s in %s ended in a draw", opponent, loc)
+	case WarOutcomeYouWon:
+		return fmt.Sprintf("fell winning a war against %s in %s", opponent, loc)
+	}
+	return fmt.Sprintf("lost a war against %s in %s", opponent, loc)
 }
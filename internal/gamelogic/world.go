@@ -0,0 +1,194 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// World is an observer's reconstruction of every player's army, built only
+// from the moves and wars published to the topic exchange.
+type World struct {
+	players  map[string]Player
+	versions map[string]int
+	source   ArmySource
+	rules    Rules
+	mu       *sync.RWMutex
+}
+
+func NewWorld() *World {
+	return &World{
+		players:  map[string]Player{},
+		versions: map[string]int{},
+		rules:    ClassicRules(),
+		mu:       &sync.RWMutex{},
+	}
+}
+
+// SetSource lets the world resync the army of a player after missing one
+// of their moves. Without a source, it waits for their next snapshot.
+func (w *World) SetSource(source ArmySource) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.source = source
+}
+
+func (w *World) Rules() Rules {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.rules
+}
+
+func (w *World) SetRules(r Rules) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rules = r
+}
+
+// ApplyMove applies a move to the mover's army and returns the move whole,
+// with the mover's army as a snapshot would carry it. After a gap, the army
+// is resynced from the source. When that isn't possible, the delta is
+// applied to what the world knows and the gap is returned with it.
+func (w *World) ApplyMove(mv ArmyMove) (ArmyMove, error) {
+	w.mu.Lock()
+	whole, err := w.applyMove(mv)
+	if err == nil || w.source == nil {
+		if err != nil {
+			whole = w.applyDelta(w.players[mv.Player.Username], mv)
+		}
+		w.mu.Unlock()
+		return whole, err
+	}
+	source := w.source
+	w.mu.Unlock()
+
+	snapshot, resyncErr := source.Army(mv.Player.Username)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if resyncErr != nil {
+		return w.applyDelta(w.players[mv.Player.Username], mv), fmt.Errorf("%v, and couldn't resync: %v", err, resyncErr)
+	}
+	return w.resync(snapshot, mv), nil
+}
+
+// ApplySpawn adds a unit spawned by a player, who may not have been seen
+// yet.
+func (w *World) ApplySpawn(username string, unit Unit) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p, ok := w.players[username]
+	if !ok {
+		p = Player{Username: username, Units: map[int]Unit{}}
+		w.players[username] = p
+	}
+	p.Units[unit.ID] = unit
+}
+
+// ApplyWar resolves a war the same way the players do and removes the
+// units lost in every battle from the armies the world knows. A war never
+// adds to them, armies are only learned from moves.
+func (w *World) ApplyWar(rw RecognitionOfWar) []Battle {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	battles := newBattles(rw, w.rules)
+	for _, b := range battles {
+		w.removeUnits(b.Attacker, b.AttackerLosses)
+		w.removeUnits(b.Defender, b.DefenderLosses)
+		for _, ally := range b.Allies {
+			w.removeUnits(ally.Username, ally.Losses)
+		}
+	}
+	return battles
+}
+
+func (w *World) removeUnits(username string, units []Unit) {
+	p, ok := w.players[username]
+	if !ok {
+		return
+	}
+	for _, u := range units {
+		delete(p.Units, u.ID)
+	}
+}
+
+// Army returns a player's army as a snapshot, with the version of the last
+// move the world applied.
+func (w *World) Army(username string) (ArmyMove, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	p, ok := w.players[username]
+	if !ok {
+		return ArmyMove{}, false
+	}
+	return ArmyMove{Player: copyPlayer(p), Version: w.versions[username]}, true
+}
+
+func (w *World) Players() []Player {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	players := []Player{}
+	for _, p := range w.players {
+		players = append(players, copyPlayer(p))
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Username < players[j].Username
+	})
+	return players
+}
+
+func (w *World) Player(username string) (Player, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	p, ok := w.players[username]
+	if !ok {
+		return Player{}, false
+	}
+	return copyPlayer(p), true
+}
+
+func (w *World) PrintMap() {
+	players := w.Players()
+	rules := w.Rules()
+	if len(players) == 0 {
+		fmt.Println("No armies have been seen yet.")
+		return
+	}
+
+	for _, loc := range rules.Map.Locations() {
+		fmt.Printf("%s:\n", loc)
+		empty := true
+		for _, p := range players {
+			units := []Unit{}
+			for _, unit := range p.Units {
+				if unit.Location == loc {
+					units = append(units, unit)
+				}
+			}
+			if len(units) == 0 {
+				continue
+			}
+			empty = false
+			sort.Slice(units, func(i, j int) bool {
+				return units[i].ID < units[j].ID
+			})
+			fmt.Printf("  %s (attack %v, defense %v):\n", p.Username, rules.Catalog.Attack(units), rules.Catalog.Defense(units))
+			for _, unit := range units {
+				fmt.Printf("    * %v: %v\n", unit.ID, unit.Rank)
+			}
+		}
+		if empty {
+			fmt.Println("  (empty)")
+		}
+	}
+}
+
+func copyPlayer(p Player) Player {
+	units := map[int]Unit{}
+	for k, v := range p.Units {
+		units[k] = v
+	}
+	return Player{
+		Username: p.Username,
+		Units:    units,
+	}
+}
@@ -0,0 +1,175 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// A player can send ChatLimit messages per ChatWindow.
+const (
+	ChatLimit  = 5
+	ChatWindow = 10 * time.Second
+)
+
+var ErrChatRateLimited = errors.New("you are sending messages too fast")
+
+// profanity is masked out of every message.
+var profanity = []string{"damn", "hell", "crap", "shit", "fuck", "bastard", "bitch", "ass", "piss"}
+
+// Chat checks the messages of players before the server delivers them.
+type Chat struct {
+	sent map[string][]time.Time
+	mu   *sync.Mutex
+}
+
+func NewChat() *Chat {
+	return &Chat{
+		sent: map[string][]time.Time{},
+		mu:   &sync.Mutex{},
+	}
+}
+
+// Admit checks that msg can be delivered: the sender and recipient play
+// the game, a team message goes to the sender's own team, and the sender
+// is not over the rate limit. It returns the message as it is delivered,
+// with profanity masked and without the sender's token, and the players it
+// is delivered to. Whispers go to the sender too, so they see what was
+// delivered.
+func (c *Chat) Admit(games *Lobby, msg routing.ChatMessage, now time.Time) (routing.ChatMessage, []string, error) {
+	game, ok := games.Get(msg.Game)
+	if !ok {
+		return routing.ChatMessage{}, nil, ErrGameNotFound
+	}
+	if !slices.Contains(game.Players, msg.Username) {
+		return routing.ChatMessage{}, nil, ErrNotInGame
+	}
+	recipients := []string{}
+	switch msg.Channel {
+	case routing.ChatGlobal:
+		msg.To, msg.Team = "", ""
+		recipients = game.Players
+	case routing.ChatDirect:
+		if msg.To == msg.Username {
+			return routing.ChatMessage{}, nil, errors.New("you can't whisper to yourself")
+		}
+		if !slices.Contains(game.Players, msg.To) {
+			return routing.ChatMessage{}, nil, fmt.Errorf("%s is not in %s", msg.To, msg.Game)
+		}
+		msg.Team = ""
+		recipients = append(recipients, msg.To, msg.Username)
+	case routing.ChatTeam:
+		if msg.Team == "" || game.Teams[msg.Username] != msg.Team {
+			return routing.ChatMessage{}, nil, errors.New("you can only talk to your own team")
+		}
+		msg.To = ""
+		for _, username := range game.Players {
+			if game.Teams[username] == msg.Team {
+				recipients = append(recipients, username)
+			}
+		}
+	default:
+		return routing.ChatMessage{}, nil, fmt.Errorf("unknown chat channel %q", msg.Channel)
+	}
+	msg.Text = strings.TrimSpace(msg.Text)
+	if msg.Text == "" {
+		return routing.ChatMessage{}, nil, errors.New("message is empty")
+	}
+	if utf8.RuneCountInString(msg.Text) > gamelogic.MaxChatLength {
+		return routing.ChatMessage{}, nil, fmt.Errorf("messages can be at most %d characters long", gamelogic.MaxChatLength)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := routing.GameKey(msg.Game, msg.Username)
+	recent := slices.DeleteFunc(c.sent[key], func(t time.Time) bool {
+		return now.Sub(t) >= ChatWindow
+	})
+	if len(recent) >= ChatLimit {
+		c.sent[key] = recent
+		return routing.ChatMessage{}, nil, ErrChatRateLimited
+	}
+	c.sent[key] = append(recent, now)
+
+	msg.Text = censor(msg.Text)
+	msg.Token = ""
+	msg.SentAt = now
+	return msg, recipients, nil
+}
+
+// censor masks every profane word of text but its first letter.
+func censor(text string) string {
+	out := strings.Builder{}
+	word := []rune{}
+	flush := func() {
+		if slices.Contains(profanity, strings.ToLower(string(word))) {
+			out.WriteRune(word[0])
+			out.WriteString(strings.Repeat("*", len(word)-1))
+		} else {
+			out.WriteString(string(word))
+		}
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		out.WriteRune(r)
+	}
+	flush()
+	return out.String()
+}
+
+// RelayChat delivers a message the chat admits to each of its recipients
+// and keeps it in the game log. A refused message is answered with a
+// whisper from the server explaining why.
+func RelayChat(pub pubsub.Publisher, games *Lobby, chat *Chat, msg routing.ChatMessage, now time.Time) error {
+	admitted, recipients, err := chat.Admit(games, msg, now)
+	if err != nil {
+		return pub.PublishJSON(
+			routing.ExchangePerilDirect,
+			routing.ChatKey(msg.Game, msg.Username),
+			routing.ChatMessage{
+				Game:     msg.Game,
+				Username: "server",
+				Channel:  routing.ChatDirect,
+				To:       msg.Username,
+				Text:     fmt.Sprintf("your message was not sent: %v", err),
+				SentAt:   now,
+			},
+		)
+	}
+
+	for _, username := range recipients {
+		if err := pub.PublishJSON(routing.ExchangePerilDirect, routing.ChatKey(admitted.Game, username), admitted); err != nil {
+			return fmt.Errorf("couldn't deliver a message from %s to %s: %v", admitted.Username, username, err)
+		}
+	}
+	audience := "everyone"
+	switch admitted.Channel {
+	case routing.ChatDirect:
+		audience = admitted.To
+	case routing.ChatTeam:
+		audience = "team " + admitted.Team
+	}
+	return pub.PublishGob(
+		routing.ExchangePerilTopic,
+		routing.GameKey(admitted.Game, routing.GameLogSlug, admitted.Username),
+		routing.GameLog{
+			CurrentTime: now,
+			Message:     fmt.Sprintf("said to %s: %s", audience, admitted.Text),
+			Username:    admitted.Username,
+		},
+	)
+}
@@ -0,0 +1,158 @@
+package lobby
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// teamGame starts a classic game called "g" with alice and bob on team red
+// and carol on team blue.
+func teamGame(t *testing.T) *Lobby {
+	t.Helper()
+	games := NewSeeded(1)
+	if _, err := games.Create("g", 4, gamelogic.ClassicRules()); err != nil {
+		t.Fatal(err)
+	}
+	for username, team := range map[string]string{"alice": "red", "bob": "red", "carol": "blue"} {
+		if _, err := games.Join("g", username, team); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := games.Start("g"); err != nil {
+		t.Fatal(err)
+	}
+	return games
+}
+
+func TestChatAdmit(t *testing.T) {
+	tests := []struct {
+		name           string
+		msg            routing.ChatMessage
+		wantErr        bool
+		wantText       string
+		wantRecipients []string
+	}{
+		{
+			name:           "global",
+			msg:            routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatGlobal, Text: "hello"},
+			wantText:       "hello",
+			wantRecipients: []string{"alice", "bob", "carol"},
+		},
+		{
+			name:           "whisper",
+			msg:            routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatDirect, To: "carol", Text: "psst"},
+			wantText:       "psst",
+			wantRecipients: []string{"alice", "carol"},
+		},
+		{
+			name:           "own team",
+			msg:            routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatTeam, Team: "red", Text: "attack asia"},
+			wantText:       "attack asia",
+			wantRecipients: []string{"alice", "bob"},
+		},
+		{
+			name:           "profanity",
+			msg:            routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatGlobal, Text: "  Damn, classic!  "},
+			wantText:       "D***, classic!",
+			wantRecipients: []string{"alice", "bob", "carol"},
+		},
+		{
+			name:           "longest message in runes",
+			msg:            routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatGlobal, Text: strings.Repeat("é", gamelogic.MaxChatLength)},
+			wantText:       strings.Repeat("é", gamelogic.MaxChatLength),
+			wantRecipients: []string{"alice", "bob", "carol"},
+		},
+		{
+			name:    "too long",
+			msg:     routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatGlobal, Text: strings.Repeat("a", gamelogic.MaxChatLength+1)},
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			msg:     routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatGlobal, Text: "   "},
+			wantErr: true,
+		},
+		{
+			name:    "another team",
+			msg:     routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatTeam, Team: "blue", Text: "hi"},
+			wantErr: true,
+		},
+		{
+			name:    "whisper to themselves",
+			msg:     routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatDirect, To: "alice", Text: "hi"},
+			wantErr: true,
+		},
+		{
+			name:    "whisper to a stranger",
+			msg:     routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatDirect, To: "dave", Text: "hi"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown channel",
+			msg:     routing.ChatMessage{Game: "g", Username: "alice", Channel: "shout", Text: "hi"},
+			wantErr: true,
+		},
+		{
+			name:    "not in the game",
+			msg:     routing.ChatMessage{Game: "g", Username: "dave", Channel: routing.ChatGlobal, Text: "hi"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown game",
+			msg:     routing.ChatMessage{Game: "h", Username: "alice", Channel: routing.ChatGlobal, Text: "hi"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games := teamGame(t)
+			tt.msg.Token = "secret"
+
+			got, recipients, err := NewChat().Admit(games, tt.msg, time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Admit() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Text != tt.wantText {
+				t.Errorf("Admit() text = %q, want %q", got.Text, tt.wantText)
+			}
+			if got.Token != "" {
+				t.Errorf("Admit() delivers the token %q", got.Token)
+			}
+			slices.Sort(recipients)
+			if !slices.Equal(recipients, tt.wantRecipients) {
+				t.Errorf("Admit() recipients = %v, want %v", recipients, tt.wantRecipients)
+			}
+		})
+	}
+}
+
+func TestChatRateLimit(t *testing.T) {
+	games := teamGame(t)
+	chat := NewChat()
+	now := time.Now()
+	msg := routing.ChatMessage{Game: "g", Username: "alice", Channel: routing.ChatGlobal, Text: "spam"}
+	for range ChatLimit {
+		if _, _, err := chat.Admit(games, msg, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := chat.Admit(games, msg, now); err != ErrChatRateLimited {
+		t.Errorf("Admit() error = %v, want %v", err, ErrChatRateLimited)
+	}
+	bob := msg
+	bob.Username = "bob"
+	if _, _, err := chat.Admit(games, bob, now); err != nil {
+		t.Errorf("bob is limited by the messages of alice: %v", err)
+	}
+	if _, _, err := chat.Admit(games, msg, now.Add(ChatWindow)); err != nil {
+		t.Errorf("alice is still limited after %v: %v", ChatWindow, err)
+	}
+}
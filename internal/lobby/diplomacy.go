@@ -0,0 +1,120 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Negotiate proposes, accepts or breaks a pact between two players of a
+// game and returns the diplomacy of the game afterwards. Breaking also
+// withdraws or declines a proposal.
+func (l *Lobby) Negotiate(req routing.DiplomacyRequest) (routing.DiplomacyState, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[req.Game]
+	if !ok {
+		return routing.DiplomacyState{}, ErrGameNotFound
+	}
+	if s.status == routing.GameStatusEnded {
+		return routing.DiplomacyState{}, ErrGameEnded
+	}
+	if !slices.Contains(s.players, req.Username) {
+		return routing.DiplomacyState{}, ErrNotInGame
+	}
+	if !slices.Contains(s.players, req.With) {
+		return routing.DiplomacyState{}, fmt.Errorf("%s is not in %s", req.With, req.Game)
+	}
+	if req.With == req.Username {
+		return routing.DiplomacyState{}, errors.New("you can't make a pact with yourself")
+	}
+
+	if team := s.teams[req.Username]; team != "" && s.teams[req.With] == team {
+		return routing.DiplomacyState{}, fmt.Errorf("%s is on your team", req.With)
+	}
+
+	pair := pactPair(req.Username, req.With)
+	switch req.Action {
+	case routing.DiplomacyPropose:
+		if req.Kind != routing.PactAlliance && req.Kind != routing.PactTruce {
+			return routing.DiplomacyState{}, fmt.Errorf("%s is not a pact", req.Kind)
+		}
+		if kind, ok := s.pacts[pair]; ok && kind == req.Kind {
+			return routing.DiplomacyState{}, fmt.Errorf("you already have a(n) %s with %s", kind, req.With)
+		}
+		if kind, ok := s.proposals[[2]string{req.With, req.Username}]; ok && kind == req.Kind {
+			// Both want the same pact, so it holds right away.
+			delete(s.proposals, [2]string{req.With, req.Username})
+			s.pacts[pair] = kind
+			break
+		}
+		s.proposals[[2]string{req.Username, req.With}] = req.Kind
+	case routing.DiplomacyAccept:
+		kind, ok := s.proposals[[2]string{req.With, req.Username}]
+		if !ok {
+			return routing.DiplomacyState{}, fmt.Errorf("%s has not proposed a pact to you", req.With)
+		}
+		delete(s.proposals, [2]string{req.With, req.Username})
+		delete(s.proposals, [2]string{req.Username, req.With})
+		s.pacts[pair] = kind
+	case routing.DiplomacyBreak:
+		_, pact := s.pacts[pair]
+		_, sent := s.proposals[[2]string{req.Username, req.With}]
+		_, received := s.proposals[[2]string{req.With, req.Username}]
+		if !pact && !sent && !received {
+			return routing.DiplomacyState{}, fmt.Errorf("you have no pact with %s", req.With)
+		}
+		delete(s.pacts, pair)
+		delete(s.proposals, [2]string{req.Username, req.With})
+		delete(s.proposals, [2]string{req.With, req.Username})
+	default:
+		return routing.DiplomacyState{}, fmt.Errorf("unknown diplomacy action %q", req.Action)
+	}
+	return s.diplomacy(), nil
+}
+
+func (l *Lobby) Diplomacy(name string) (routing.DiplomacyState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.DiplomacyState{}, ErrGameNotFound
+	}
+	return s.diplomacy(), nil
+}
+
+// diplomacy returns the pacts and proposals of the game in a stable order.
+// l.mu must be held.
+func (s *session) diplomacy() routing.DiplomacyState {
+	state := routing.DiplomacyState{
+		Game:      s.name,
+		Pacts:     []routing.Pact{},
+		Proposals: []routing.Proposal{},
+		Teams:     s.currentTeams(),
+	}
+	for pair, kind := range s.pacts {
+		state.Pacts = append(state.Pacts, routing.Pact{Kind: kind, Players: pair})
+	}
+	for pair, kind := range s.proposals {
+		state.Proposals = append(state.Proposals, routing.Proposal{From: pair[0], To: pair[1], Kind: kind})
+	}
+	sort.Slice(state.Pacts, func(i, j int) bool {
+		a, b := state.Pacts[i].Players, state.Pacts[j].Players
+		return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+	})
+	sort.Slice(state.Proposals, func(i, j int) bool {
+		a, b := state.Proposals[i], state.Proposals[j]
+		return a.From < b.From || a.From == b.From && a.To < b.To
+	})
+	return state
+}
+
+func pactPair(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
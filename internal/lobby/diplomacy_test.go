@@ -0,0 +1,202 @@
+package lobby
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestNegotiate(t *testing.T) {
+	propose := func(from, to string, kind routing.PactKind) routing.DiplomacyRequest {
+		return routing.DiplomacyRequest{Game: "g", Username: from, Action: routing.DiplomacyPropose, With: to, Kind: kind}
+	}
+	accept := func(from, to string) routing.DiplomacyRequest {
+		return routing.DiplomacyRequest{Game: "g", Username: from, Action: routing.DiplomacyAccept, With: to}
+	}
+	breakOff := func(from, to string) routing.DiplomacyRequest {
+		return routing.DiplomacyRequest{Game: "g", Username: from, Action: routing.DiplomacyBreak, With: to}
+	}
+	tests := []struct {
+		name          string
+		reqs          []routing.DiplomacyRequest
+		wantErr       bool
+		wantPacts     []routing.Pact
+		wantProposals []routing.Proposal
+	}{
+		{
+			name:          "proposal",
+			reqs:          []routing.DiplomacyRequest{propose("carol", "alice", routing.PactAlliance)},
+			wantPacts:     []routing.Pact{},
+			wantProposals: []routing.Proposal{{From: "carol", To: "alice", Kind: routing.PactAlliance}},
+		},
+		{
+			name:          "accepted proposal",
+			reqs:          []routing.DiplomacyRequest{propose("carol", "alice", routing.PactTruce), accept("alice", "carol")},
+			wantPacts:     []routing.Pact{{Kind: routing.PactTruce, Players: [2]string{"alice", "carol"}}},
+			wantProposals: []routing.Proposal{},
+		},
+		{
+			name:          "same pact proposed by both",
+			reqs:          []routing.DiplomacyRequest{propose("carol", "alice", routing.PactAlliance), propose("alice", "carol", routing.PactAlliance)},
+			wantPacts:     []routing.Pact{{Kind: routing.PactAlliance, Players: [2]string{"alice", "carol"}}},
+			wantProposals: []routing.Proposal{},
+		},
+		{
+			name: "truce turned into an alliance",
+			reqs: []routing.DiplomacyRequest{
+				propose("carol", "alice", routing.PactTruce), accept("alice", "carol"),
+				propose("alice", "carol", routing.PactAlliance), accept("carol", "alice"),
+			},
+			wantPacts:     []routing.Pact{{Kind: routing.PactAlliance, Players: [2]string{"alice", "carol"}}},
+			wantProposals: []routing.Proposal{},
+		},
+		{
+			name:          "broken pact",
+			reqs:          []routing.DiplomacyRequest{propose("carol", "alice", routing.PactAlliance), accept("alice", "carol"), breakOff("carol", "alice")},
+			wantPacts:     []routing.Pact{},
+			wantProposals: []routing.Proposal{},
+		},
+		{
+			name:          "declined proposal",
+			reqs:          []routing.DiplomacyRequest{propose("carol", "bob", routing.PactAlliance), breakOff("bob", "carol")},
+			wantPacts:     []routing.Pact{},
+			wantProposals: []routing.Proposal{},
+		},
+		{
+			name:    "pact already made",
+			reqs:    []routing.DiplomacyRequest{propose("carol", "alice", routing.PactTruce), accept("alice", "carol"), propose("carol", "alice", routing.PactTruce)},
+			wantErr: true,
+		},
+		{
+			name:    "accepting what wasn't proposed",
+			reqs:    []routing.DiplomacyRequest{propose("carol", "alice", routing.PactTruce), accept("carol", "alice")},
+			wantErr: true,
+		},
+		{
+			name:    "breaking without a pact",
+			reqs:    []routing.DiplomacyRequest{breakOff("carol", "alice")},
+			wantErr: true,
+		},
+		{
+			name:    "team pact",
+			reqs:    []routing.DiplomacyRequest{propose("carol", "alice", routing.PactTeam)},
+			wantErr: true,
+		},
+		{
+			name:    "teammates",
+			reqs:    []routing.DiplomacyRequest{propose("alice", "bob", routing.PactAlliance)},
+			wantErr: true,
+		},
+		{
+			name:    "with themselves",
+			reqs:    []routing.DiplomacyRequest{propose("carol", "carol", routing.PactAlliance)},
+			wantErr: true,
+		},
+		{
+			name:    "with a stranger",
+			reqs:    []routing.DiplomacyRequest{propose("carol", "dave", routing.PactAlliance)},
+			wantErr: true,
+		},
+		{
+			name:    "not in the game",
+			reqs:    []routing.DiplomacyRequest{propose("dave", "carol", routing.PactAlliance)},
+			wantErr: true,
+		},
+		{
+			name:    "unknown action",
+			reqs:    []routing.DiplomacyRequest{{Game: "g", Username: "carol", Action: "betray", With: "alice"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games := teamGame(t)
+			var state routing.DiplomacyState
+			var err error
+			for i, req := range tt.reqs {
+				state, err = games.Negotiate(req)
+				if err != nil && i < len(tt.reqs)-1 {
+					t.Fatal(err)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Negotiate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(state.Pacts, tt.wantPacts) {
+				t.Errorf("Negotiate() pacts = %v, want %v", state.Pacts, tt.wantPacts)
+			}
+			if !reflect.DeepEqual(state.Proposals, tt.wantProposals) {
+				t.Errorf("Negotiate() proposals = %v, want %v", state.Proposals, tt.wantProposals)
+			}
+		})
+	}
+}
+
+func TestNegotiateEndedGame(t *testing.T) {
+	games := teamGame(t)
+	if _, err := games.End("g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := games.Negotiate(routing.DiplomacyRequest{Game: "g", Username: "carol", Action: routing.DiplomacyPropose, With: "alice", Kind: routing.PactTruce}); err == nil {
+		t.Error("negotiated in an ended game")
+	}
+}
+
+func TestWarBetweenPacts(t *testing.T) {
+	tests := []struct {
+		name       string
+		pact       routing.PactKind
+		attacker   string
+		wantErr    bool
+		wantAllies []string
+	}{
+		{name: "no pact", attacker: "carol", wantAllies: []string{"bob"}},
+		{name: "teammates", attacker: "bob", wantErr: true},
+		{name: "truce", pact: routing.PactTruce, attacker: "carol", wantErr: true},
+		{name: "alliance", pact: routing.PactAlliance, attacker: "carol", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games := teamGame(t)
+			for _, req := range []routing.SpendRequest{
+				{Game: "g", Username: "alice", UnitID: 1, Rank: "infantry", Location: "asia"},
+				{Game: "g", Username: "bob", UnitID: 1, Rank: "infantry", Location: "asia"},
+				{Game: "g", Username: "carol", UnitID: 1, Rank: "infantry", Location: "asia"},
+			} {
+				if _, err := games.Spend(req); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.pact != "" {
+				for _, req := range []routing.DiplomacyRequest{
+					{Game: "g", Username: "carol", Action: routing.DiplomacyPropose, With: "alice", Kind: tt.pact},
+					{Game: "g", Username: "alice", Action: routing.DiplomacyAccept, With: "carol"},
+				} {
+					if _, err := games.Negotiate(req); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			rw, _, err := games.War("g", gamelogic.WarDeclaration{Attacker: tt.attacker, Defender: "alice", Location: "asia"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("War() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			allies := []string{}
+			for _, p := range rw.Allies {
+				allies = append(allies, p.Username)
+			}
+			if !reflect.DeepEqual(allies, tt.wantAllies) {
+				t.Errorf("War() allies = %v, want %v", allies, tt.wantAllies)
+			}
+		})
+	}
+}
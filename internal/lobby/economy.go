@@ -0,0 +1,225 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// IncomeInterval is how often players are paid in games that are not in
+// turn mode. In turn mode they are paid when a round is resolved.
+const IncomeInterval = 30 * time.Second
+
+var ErrNotEnoughGold = errors.New("not enough gold")
+
+// treasury returns the treasury of a player, opening it with the starting
+// gold the first time. l.mu must be held.
+func (s *session) treasury(username string) *routing.Treasury {
+	t, ok := s.treasuries[username]
+	if !ok {
+		t = &routing.Treasury{
+			Game:     s.name,
+			Username: username,
+			Gold:     s.rules.Economy.StartingGold,
+		}
+		s.treasuries[username] = t
+	}
+	return t
+}
+
+func (l *Lobby) Treasury(name, username string) (routing.Treasury, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.Treasury{}, ErrGameNotFound
+	}
+	if !slices.Contains(s.players, username) {
+		return routing.Treasury{}, ErrNotInGame
+	}
+	return *s.treasury(username), nil
+}
+
+// Spend pays for a unit out of the player's treasury. The cost comes from
+// the game's own catalog, never from the player, and the unit can't take
+// the ID of one the player already has.
+func (l *Lobby) Spend(req routing.SpendRequest) (routing.Treasury, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[req.Game]
+	if !ok {
+		return routing.Treasury{}, ErrGameNotFound
+	}
+	if !slices.Contains(s.players, req.Username) {
+		return routing.Treasury{}, ErrNotInGame
+	}
+	if s.status != routing.GameStatusRunning || s.paused {
+		return routing.Treasury{}, errors.New("units can only be spawned while the game is running")
+	}
+	unit := gamelogic.Unit{
+		ID:       req.UnitID,
+		Rank:     gamelogic.UnitRank(req.Rank),
+		Location: gamelogic.Location(req.Location),
+	}
+	t, ok := s.rules.Catalog.Lookup(unit.Rank)
+	if !ok {
+		return routing.Treasury{}, fmt.Errorf("%s is not a valid unit", unit.Rank)
+	}
+	if !s.rules.Map.Has(unit.Location) {
+		return routing.Treasury{}, fmt.Errorf("%s is not a valid location", unit.Location)
+	}
+	if army, ok := s.world.Player(req.Username); ok {
+		if _, taken := army.Units[unit.ID]; taken {
+			return routing.Treasury{}, fmt.Errorf("you already have a unit %d", unit.ID)
+		}
+	}
+
+	treasury := s.treasury(req.Username)
+	if treasury.Gold < t.Cost {
+		return routing.Treasury{}, fmt.Errorf("%w: a(n) %s costs %d, you have %d", ErrNotEnoughGold, unit.Rank, t.Cost, treasury.Gold)
+	}
+	treasury.Gold -= t.Cost
+	s.world.ApplySpawn(req.Username, unit)
+	s.fielded[s.side(req.Username)] = true
+	return *treasury, nil
+}
+
+// Army returns the army of a player of a game as the server knows it, for
+// players who lost track of it. Armies are secret in fog of war.
+func (l *Lobby) Army(name, username string) (gamelogic.ArmyMove, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return gamelogic.ArmyMove{}, ErrGameNotFound
+	}
+	if s.rules.Fog {
+		return gamelogic.ArmyMove{}, errors.New("armies are hidden in fog of war")
+	}
+	if !slices.Contains(s.players, username) {
+		return gamelogic.ArmyMove{}, ErrNotInGame
+	}
+	army, ok := s.world.Army(username)
+	if !ok {
+		return gamelogic.ArmyMove{Player: gamelogic.Player{Username: username, Units: map[int]gamelogic.Unit{}}}, nil
+	}
+	return army, nil
+}
+
+// Armies returns the army of every player of a game as the server knows
+// it, fog of war or not, so it is never meant for the players.
+func (l *Lobby) Armies(name string) (map[string]gamelogic.Player, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	armies := map[string]gamelogic.Player{}
+	for _, username := range s.players {
+		p, ok := s.world.Player(username)
+		if !ok {
+			p = gamelogic.Player{Username: username, Units: map[int]gamelogic.Unit{}}
+		}
+		armies[username] = p
+	}
+	return armies, nil
+}
+
+// Payday pays every player of a game their income and returns the
+// treasuries to announce. Income comes from the locations held in the
+// server's world, which only ever takes moves Move has checked.
+func (l *Lobby) Payday(name string) ([]routing.Treasury, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	s.payday++
+	paid := []routing.Treasury{}
+	for _, username := range s.players {
+		p, ok := s.world.Player(username)
+		if !ok {
+			p = gamelogic.Player{Username: username}
+		}
+		t := s.treasury(username)
+		t.Income = s.rules.Income(p).Total
+		t.Gold += t.Income
+		t.Round = s.payday
+		paid = append(paid, *t)
+	}
+	return paid, nil
+}
+
+// PayIncome runs a payday and sends every player their new treasury.
+func PayIncome(pub pubsub.Publisher, games *Lobby, name string) error {
+	paid, err := games.Payday(name)
+	if err != nil {
+		return err
+	}
+	for _, t := range paid {
+		if err := pub.PublishJSON(
+			routing.ExchangePerilDirect,
+			routing.GameKey(name, routing.TreasuryKey, t.Username),
+			t,
+		); err != nil {
+			return fmt.Errorf("couldn't pay %s: %v", t.Username, err)
+		}
+	}
+	return nil
+}
+
+// PayDue pays the players of every running game that is not in turn mode,
+// then judges whether the game is over.
+func PayDue(pub pubsub.Publisher, games *Lobby, turns *Turns, now time.Time) error {
+	errs := []error{}
+	for _, game := range games.List() {
+		if game.Status != routing.GameStatusRunning || game.Paused || turns.Enabled(game.Name) {
+			continue
+		}
+		if err := PayIncome(pub, games, game.Name); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := JudgeGame(pub, games, game.Name, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LocalBank pays for spawns straight out of a lobby in the same process.
+type LocalBank struct {
+	Games    *Lobby
+	Game     string
+	Username string
+}
+
+func (b LocalBank) Spend(unit gamelogic.Unit) (routing.Treasury, error) {
+	return b.Games.Spend(spendRequest(b.Game, b.Username, unit))
+}
+
+// LocalArmies resyncs armies straight from a lobby in the same process.
+type LocalArmies struct {
+	Games *Lobby
+	Game  string
+}
+
+func (a LocalArmies) Army(username string) (gamelogic.ArmyMove, error) {
+	return a.Games.Army(a.Game, username)
+}
+
+func spendRequest(game, username string, unit gamelogic.Unit) routing.SpendRequest {
+	return routing.SpendRequest{
+		Game:     game,
+		Username: username,
+		UnitID:   unit.ID,
+		Rank:     string(unit.Rank),
+		Location: string(unit.Location),
+	}
+}
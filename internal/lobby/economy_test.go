@@ -0,0 +1,117 @@
+package lobby
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// runningGame starts a classic game called "g" with alice and bob in it.
+func runningGame(t *testing.T) *Lobby {
+	t.Helper()
+	games := NewSeeded(1)
+	if _, err := games.Create("g", 4, gamelogic.ClassicRules()); err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"alice", "bob"} {
+		if _, err := games.Join("g", username, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := games.Start("g"); err != nil {
+		t.Fatal(err)
+	}
+	return games
+}
+
+func TestSpend(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      routing.SpendRequest
+		wantErr  bool
+		wantPoor bool
+		wantGold int
+	}{
+		{
+			name:     "infantry",
+			req:      routing.SpendRequest{Game: "g", Username: "alice", UnitID: 2, Rank: "infantry", Location: "europe"},
+			wantGold: 8,
+		},
+		{
+			name:     "unit the player already has",
+			req:      routing.SpendRequest{Game: "g", Username: "alice", UnitID: 1, Rank: "infantry", Location: "asia"},
+			wantErr:  true,
+			wantGold: 9,
+		},
+		{
+			name:     "unit ID another player has",
+			req:      routing.SpendRequest{Game: "g", Username: "bob", UnitID: 1, Rank: "infantry", Location: "asia"},
+			wantGold: 9,
+		},
+		{
+			name:     "too expensive",
+			req:      routing.SpendRequest{Game: "g", Username: "alice", UnitID: 2, Rank: "artillery", Location: "europe"},
+			wantErr:  true,
+			wantPoor: true,
+			wantGold: 9,
+		},
+		{
+			name:     "unknown rank",
+			req:      routing.SpendRequest{Game: "g", Username: "alice", UnitID: 2, Rank: "dragon", Location: "europe"},
+			wantErr:  true,
+			wantGold: 9,
+		},
+		{
+			name:     "unknown location",
+			req:      routing.SpendRequest{Game: "g", Username: "alice", UnitID: 2, Rank: "infantry", Location: "atlantis"},
+			wantErr:  true,
+			wantGold: 9,
+		},
+		{
+			name:    "not in the game",
+			req:     routing.SpendRequest{Game: "g", Username: "carol", UnitID: 1, Rank: "infantry", Location: "europe"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown game",
+			req:     routing.SpendRequest{Game: "h", Username: "alice", UnitID: 2, Rank: "infantry", Location: "europe"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games := runningGame(t)
+			// Out of her 10 gold, alice already paid for infantry 1.
+			if _, err := games.Spend(routing.SpendRequest{Game: "g", Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"}); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := games.Spend(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Spend() error = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrNotEnoughGold) != tt.wantPoor {
+				t.Errorf("Spend() error = %v, want not enough gold %v", err, tt.wantPoor)
+			}
+			treasury, err := games.Treasury(tt.req.Game, tt.req.Username)
+			if err != nil {
+				return
+			}
+			if treasury.Gold != tt.wantGold {
+				t.Errorf("%s has %d gold, want %d", tt.req.Username, treasury.Gold, tt.wantGold)
+			}
+		})
+	}
+}
+
+func TestSpendPausedGame(t *testing.T) {
+	games := runningGame(t)
+	if _, err := games.SetPaused("g", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := games.Spend(routing.SpendRequest{Game: "g", Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"}); err == nil {
+		t.Error("spawned a unit in a paused game")
+	}
+}
@@ -0,0 +1,92 @@
+package lobby
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Sightings checks a move and applies it to a game played in fog of war,
+// and returns what every player sees of it. The mover sees their whole
+// move, and scouts the locations around their units. Other players only
+// hear of the move when it happens in sight: moved units arrive there, or
+// leave it.
+func (l *Lobby) Sightings(name string, in gamelogic.MoveIntent, now time.Time) ([]gamelogic.Sighting, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	whole, err := s.validMove(in)
+	if err != nil {
+		return nil, err
+	}
+	mover := whole.Player.Username
+	prior, _ := s.world.Player(mover)
+	s.world.ApplyMove(whole)
+
+	sightings := []gamelogic.Sighting{}
+	for _, username := range s.players {
+		viewer, _ := s.world.Player(username)
+		sight := s.rules.Map.Sight(viewer)
+		if username == mover {
+			sightings = append(sightings, gamelogic.Sighting{Viewer: username, Move: whole, Visible: sight, SeenAt: now})
+			continue
+		}
+		seen := whole.Within(sight)
+		left := false
+		for _, unit := range whole.Units {
+			if from, ok := prior.Units[unit.ID]; ok && slices.Contains(sight, from.Location) {
+				left = true
+			}
+		}
+		if len(seen.Units) == 0 {
+			if !left {
+				continue
+			}
+			// The units went out of sight, and so did where they went.
+			seen.ToLocation = ""
+		}
+		sightings = append(sightings, gamelogic.Sighting{Viewer: username, Move: seen, Visible: sight, SeenAt: now})
+	}
+
+	moved, _ := s.world.Player(mover)
+	sight := s.rules.Map.Sight(moved)
+	for _, username := range s.players {
+		if username == mover {
+			continue
+		}
+		p, _ := s.world.Player(username)
+		seen := gamelogic.ArmyMove{Player: p}.Within(sight)
+		if len(seen.Player.Units) == 0 {
+			continue
+		}
+		seen.ToLocation = ""
+		sightings = append(sightings, gamelogic.Sighting{Viewer: mover, Move: seen, Visible: sight, Scouted: true, SeenAt: now})
+	}
+	return sightings, nil
+}
+
+// RelayMove sends every player of a game played in fog of war what they
+// see of a move.
+func RelayMove(pub pubsub.Publisher, games *Lobby, name string, in gamelogic.MoveIntent, now time.Time) error {
+	sightings, err := games.Sightings(name, in, now)
+	if err != nil {
+		return err
+	}
+	for _, s := range sightings {
+		if err := pub.PublishJSON(
+			routing.ExchangePerilDirect,
+			routing.GameKey(name, routing.SightingsKey, s.Viewer),
+			s,
+		); err != nil {
+			return fmt.Errorf("couldn't tell %s about a move of %s: %v", s.Viewer, in.Username, err)
+		}
+	}
+	return nil
+}
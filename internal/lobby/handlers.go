@@ -0,0 +1,84 @@
+package lobby
+
+import (
+	"slices"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Logf reports what the handlers below did with a message. The server
+// prints it to its console, the sim drops it.
+type Logf func(format string, args ...any)
+
+// HandlerIntent publishes the moves players send for game name, once the
+// server has checked them. A move only counts when it was sent on its
+// player's own key, the one bound when they joined, with their token.
+func HandlerIntent(pub pubsub.Publisher, games *Lobby, turns *Turns, players *Registry, name string, now func() time.Time, logf Logf) func(string, gamelogic.MoveIntent) pubsub.AckType {
+	return func(key string, in gamelogic.MoveIntent) pubsub.AckType {
+		if key != routing.GameKey(name, routing.MoveIntentsPrefix, in.Username) {
+			logf("Discarding a move of %s in %s sent on %s", in.Username, name, key)
+			return pubsub.AckTypeNackDiscard
+		}
+		if err := players.Authenticate(in.Username, in.Token); err != nil {
+			logf("Discarding a move of %s in %s: %v", in.Username, name, err)
+			return pubsub.AckTypeNackDiscard
+		}
+		if err := PublishIntent(pub, games, turns, name, in, now()); err != nil {
+			logf("Couldn't publish a move of %s in %s: %v", in.Username, name, err)
+			return pubsub.AckTypeNackDiscard
+		}
+		return pubsub.AckTypeAck
+	}
+}
+
+// HandlerWar fights the wars declared in game name and publishes them to
+// every side. Only the defender declares a war, on their own key, the one
+// bound when they joined, with their token.
+func HandlerWar(pub pubsub.Publisher, games *Lobby, players *Registry, name string, logf Logf) func(string, gamelogic.WarDeclaration) pubsub.AckType {
+	return func(key string, decl gamelogic.WarDeclaration) pubsub.AckType {
+		if key != routing.GameKey(name, routing.WarDeclarationsPrefix, decl.Defender) {
+			logf("Discarding a war of %s in %s sent on %s", decl.Defender, name, key)
+			return pubsub.AckTypeNackDiscard
+		}
+		if err := players.Authenticate(decl.Defender, decl.Token); err != nil {
+			logf("Discarding a war of %s in %s: %v", decl.Defender, name, err)
+			return pubsub.AckTypeNackDiscard
+		}
+		if err := PublishWar(pub, games, name, decl); err != nil {
+			logf("Couldn't publish a war of %s in %s: %v", decl.Defender, name, err)
+			return pubsub.AckTypeNackDiscard
+		}
+		return pubsub.AckTypeAck
+	}
+}
+
+// HandlerOrders queues the orders players submit with their token for the
+// current round of their game.
+func HandlerOrders(games *Lobby, turns *Turns, players *Registry, logf Logf) func(gamelogic.OrderSet) pubsub.AckType {
+	return func(orders gamelogic.OrderSet) pubsub.AckType {
+		if err := players.Authenticate(orders.Username, orders.Token); err != nil {
+			logf("Discarding orders from %s: %v", orders.Username, err)
+			return pubsub.AckTypeNackDiscard
+		}
+		game, ok := games.Get(orders.Game)
+		if !ok || !slices.Contains(game.Players, orders.Username) {
+			logf("Discarding orders from %s: not in game %s", orders.Username, orders.Game)
+			return pubsub.AckTypeNackDiscard
+		}
+		for _, mv := range orders.Moves {
+			if mv.Username != orders.Username {
+				logf("Discarding orders from %s: move for %s", orders.Username, mv.Username)
+				return pubsub.AckTypeNackDiscard
+			}
+		}
+		if err := turns.Submit(orders); err != nil {
+			logf("Discarding orders from %s: %v", orders.Username, err)
+			return pubsub.AckTypeNackDiscard
+		}
+		logf("%s submitted %d order(s) for round %d of %s", orders.Username, len(orders.Moves), orders.Round, orders.Game)
+		return pubsub.AckTypeAck
+	}
+}
@@ -0,0 +1,82 @@
+package lobby
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// published keeps the keys messages were published to.
+type published []string
+
+func (p *published) PublishJSON(exchange, key string, val any) error {
+	*p = append(*p, key)
+	return nil
+}
+
+func (p *published) PublishGob(exchange, key string, val any) error {
+	*p = append(*p, key)
+	return nil
+}
+
+func TestHandlerIntentAuthenticates(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		intent gamelogic.MoveIntent
+		want   pubsub.AckType
+	}{
+		{
+			name:   "own key and token",
+			key:    routing.GameKey("g", routing.MoveIntentsPrefix, "alice"),
+			intent: gamelogic.MoveIntent{Username: "alice", ToLocation: "europe", UnitIDs: []int{1}},
+			want:   pubsub.AckTypeAck,
+		},
+		{
+			name:   "wrong token",
+			key:    routing.GameKey("g", routing.MoveIntentsPrefix, "alice"),
+			intent: gamelogic.MoveIntent{Username: "alice", Token: "guess", ToLocation: "europe", UnitIDs: []int{1}},
+			want:   pubsub.AckTypeNackDiscard,
+		},
+		{
+			name:   "another player's key",
+			key:    routing.GameKey("g", routing.MoveIntentsPrefix, "bob"),
+			intent: gamelogic.MoveIntent{Username: "alice", ToLocation: "europe", UnitIDs: []int{1}},
+			want:   pubsub.AckTypeNackDiscard,
+		},
+		{
+			name:   "unregistered player",
+			key:    routing.GameKey("g", routing.MoveIntentsPrefix, "carol"),
+			intent: gamelogic.MoveIntent{Username: "carol", Token: "guess", ToLocation: "europe", UnitIDs: []int{1}},
+			want:   pubsub.AckTypeNackDiscard,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games := runningGame(t)
+			players := NewRegistry()
+			token, err := players.Register("alice", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.intent.Token == "" {
+				tt.intent.Token = token
+			}
+			if _, err := games.Spend(routing.SpendRequest{Game: "g", Username: "alice", UnitID: 1, Rank: "infantry", Location: "asia"}); err != nil {
+				t.Fatal(err)
+			}
+
+			pub := &published{}
+			handler := HandlerIntent(pub, games, NewTurns(), players, "g", time.Now, t.Logf)
+			if got := handler(tt.key, tt.intent); got != tt.want {
+				t.Errorf("handler() = %v, want %v", got, tt.want)
+			}
+			if moved := len(*pub) > 0; moved != (tt.want == pubsub.AckTypeAck) {
+				t.Errorf("published %v", *pub)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const DefaultMaxPlayers = 4
+
+var (
+	ErrGameNotFound = errors.New("game not found")
+	ErrGameExists   = errors.New("a game with that name already exists")
+	ErrGameFull     = errors.New("game is full")
+	ErrGameEnded    = errors.New("game has ended")
+	ErrNotInGame    = errors.New("player is not in this game")
+)
+
+type session struct {
+	name       string
+	status     routing.GameStatus
+	maxPlayers int
+	players    []string
+	paused     bool
+	// pauseSeq counts the changes to paused.
+	pauseSeq int
+	rules    gamelogic.Rules
+	world    *gamelogic.World
+	// sent numbers the moves the server publishes.
+	sent       map[string]*gamelogic.SentArmy
+	treasuries map[string]*routing.Treasury
+	payday     int
+	// pacts are keyed by the players in name order, proposals by who
+	// proposed to whom.
+	pacts     map[[2]string]routing.PactKind
+	proposals map[[2]string]routing.PactKind
+	teams     map[string]string
+	// held counts the paydays in a row every side held enough territories
+	// to win, fielded the sides that ever had units.
+	held    map[string]int
+	fielded map[string]bool
+}
+
+func (s *session) summary() routing.GameSummary {
+	return routing.GameSummary{
+		Name:       s.name,
+		Status:     s.status,
+		MaxPlayers: s.maxPlayers,
+		Players:    slices.Clone(s.players),
+		Paused:     s.paused,
+		Map:        s.rules.Map.Name,
+		Catalog:    s.rules.Catalog.Name,
+		Combat:     s.rules.Resolver().Name(),
+		Teams:      s.currentTeams(),
+	}
+}
+
+type Lobby struct {
+	sessions map[string]*session
+	// rng seeds the dice of every war, so no player picks them.
+	rng *rand.Rand
+	mu  *sync.RWMutex
+}
+
+func New() *Lobby {
+	return NewSeeded(rand.Int63())
+}
+
+// NewSeeded is a lobby whose wars are always fought with the same dice, e.g.
+// in a simulation.
+func NewSeeded(seed int64) *Lobby {
+	return &Lobby{
+		sessions: map[string]*session{},
+		rng:      rand.New(rand.NewSource(seed)),
+		mu:       &sync.RWMutex{},
+	}
+}
+
+// Create opens a game played by rules. A missing map or unit catalog is
+// replaced by the classic one.
+func (l *Lobby) Create(name string, maxPlayers int, rules gamelogic.Rules) (routing.GameSummary, error) {
+	if err := validateGameName(name); err != nil {
+		return routing.GameSummary{}, err
+	}
+	if maxPlayers < 2 {
+		return routing.GameSummary{}, errors.New("a game needs room for at least 2 players")
+	}
+	if rules.Map == nil {
+		rules.Map = gamelogic.ClassicMap()
+	}
+	if rules.Catalog == nil {
+		rules.Catalog = gamelogic.ClassicCatalog()
+	}
+	if err := rules.Economy.Validate(); err != nil {
+		return routing.GameSummary{}, err
+	}
+	if _, err := gamelogic.NewCombatResolver(rules.Combat); err != nil {
+		return routing.GameSummary{}, err
+	}
+	if err := rules.Victory.Validate(); err != nil {
+		return routing.GameSummary{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.sessions[name]; ok {
+		return routing.GameSummary{}, ErrGameExists
+	}
+	s := &session{
+		name:       name,
+		status:     routing.GameStatusWaiting,
+		maxPlayers: maxPlayers,
+		players:    []string{},
+		paused:     true,
+		rules:      rules,
+		world:      gamelogic.NewWorld(),
+		sent:       map[string]*gamelogic.SentArmy{},
+		treasuries: map[string]*routing.Treasury{},
+		pacts:      map[[2]string]routing.PactKind{},
+		proposals:  map[[2]string]routing.PactKind{},
+		teams:      map[string]string{},
+		held:       map[string]int{},
+		fielded:    map[string]bool{},
+	}
+	s.world.SetRules(rules)
+	l.sessions[name] = s
+	return s.summary(), nil
+}
+
+// Join seats a player in a game, on team unless it is empty. The team is
+// checked before the player takes a seat, so a team that is refused never
+// leaves them in the game.
+func (l *Lobby) Join(name, username, team string) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.GameSummary{}, ErrGameNotFound
+	}
+	if s.status == routing.GameStatusEnded {
+		return routing.GameSummary{}, ErrGameEnded
+	}
+	for _, t := range s.teams {
+		if t == username {
+			return routing.GameSummary{}, fmt.Errorf("%s is the name of a team in %s", username, name)
+		}
+	}
+	if team != "" && s.teams[username] != team {
+		if err := s.checkTeam(username, team); err != nil {
+			return routing.GameSummary{}, err
+		}
+	}
+	if !slices.Contains(s.players, username) {
+		if len(s.players) >= s.maxPlayers {
+			return routing.GameSummary{}, ErrGameFull
+		}
+		s.players = append(s.players, username)
+		s.treasury(username)
+	}
+	if team != "" {
+		s.teams[username] = team
+	}
+	return s.summary(), nil
+}
+
+func (l *Lobby) Leave(name, username string) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.GameSummary{}, ErrGameNotFound
+	}
+	if !s.remove(username) {
+		return routing.GameSummary{}, ErrNotInGame
+	}
+	return s.summary(), nil
+}
+
+// LeaveAll removes username from every game they are in, e.g. when they
+// unregister or go offline, and returns those games.
+func (l *Lobby) LeaveAll(username string) []routing.GameSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	left := []routing.GameSummary{}
+	for _, s := range l.sessions {
+		if s.remove(username) {
+			left = append(left, s.summary())
+		}
+	}
+	sort.Slice(left, func(i, j int) bool {
+		return left[i].Name < left[j].Name
+	})
+	return left
+}
+
+// remove takes a player out of a game along with their team and treasury,
+// so joining again starts afresh. l.mu must be held.
+func (s *session) remove(username string) bool {
+	i := slices.Index(s.players, username)
+	if i == -1 {
+		return false
+	}
+	s.players = slices.Delete(s.players, i, i+1)
+	delete(s.teams, username)
+	delete(s.treasuries, username)
+	return true
+}
+
+func (l *Lobby) Start(name string) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.GameSummary{}, ErrGameNotFound
+	}
+	switch s.status {
+	case routing.GameStatusRunning:
+		return routing.GameSummary{}, errors.New("game is already running")
+	case routing.GameStatusEnded:
+		return routing.GameSummary{}, ErrGameEnded
+	}
+	if len(s.players) < 2 {
+		return routing.GameSummary{}, fmt.Errorf("game needs at least 2 players, it has %d", len(s.players))
+	}
+	s.status = routing.GameStatusRunning
+	s.setPaused(false)
+	return s.summary(), nil
+}
+
+func (l *Lobby) End(name string) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.GameSummary{}, ErrGameNotFound
+	}
+	if s.status == routing.GameStatusEnded {
+		return routing.GameSummary{}, ErrGameEnded
+	}
+	s.status = routing.GameStatusEnded
+	s.setPaused(true)
+	return s.summary(), nil
+}
+
+// SetPaused pauses or resumes a running game. Games that are waiting for
+// players or have ended always stay paused.
+func (l *Lobby) SetPaused(name string, paused bool) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.GameSummary{}, ErrGameNotFound
+	}
+	if s.status != routing.GameStatusRunning {
+		return routing.GameSummary{}, fmt.Errorf("game is %s, only running games can be paused or resumed", s.status)
+	}
+	s.setPaused(paused)
+	return s.summary(), nil
+}
+
+// PlayingState is the pause state of a game as the players are told it.
+func (l *Lobby) PlayingState(name string) (routing.PlayingState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.PlayingState{}, ErrGameNotFound
+	}
+	return routing.PlayingState{IsPaused: s.paused, Seq: s.pauseSeq}, nil
+}
+
+// PublishPause tells every player of a game its current pause state.
+func PublishPause(pub pubsub.Publisher, games *Lobby, name string) error {
+	state, err := games.PlayingState(name)
+	if err != nil {
+		return err
+	}
+	return pub.PublishJSON(routing.ExchangePerilDirect, routing.GameKey(name, routing.PauseKey), state)
+}
+
+func (s *session) setPaused(paused bool) {
+	s.paused = paused
+	s.pauseSeq++
+}
+
+func (l *Lobby) Get(name string) (routing.GameSummary, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.GameSummary{}, false
+	}
+	return s.summary(), true
+}
+
+func (l *Lobby) Rules(name string) (gamelogic.Rules, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return gamelogic.Rules{}, ErrGameNotFound
+	}
+	return s.rules, nil
+}
+
+func (l *Lobby) List() []routing.GameSummary {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	games := []routing.GameSummary{}
+	for _, s := range l.sessions {
+		games = append(games, s.summary())
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].Name < games[j].Name
+	})
+	return games
+}
+
+func validateGameName(name string) error {
+	if name == "" {
+		return errors.New("game name can not be empty")
+	}
+	if strings.ContainsAny(name, ".*# ") {
+		return fmt.Errorf("game name %q can not contain '.', '*', '#' or spaces", name)
+	}
+	return nil
+}
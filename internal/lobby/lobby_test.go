@@ -0,0 +1,64 @@
+package lobby
+
+import (
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestLeaveClearsTeamAndTreasury(t *testing.T) {
+	for _, leave := range []struct {
+		name string
+		fn   func(*Lobby) error
+	}{
+		{"Leave", func(games *Lobby) error {
+			_, err := games.Leave("g", "alice")
+			return err
+		}},
+		{"LeaveAll", func(games *Lobby) error {
+			games.LeaveAll("alice")
+			return nil
+		}},
+	} {
+		t.Run(leave.name, func(t *testing.T) {
+			games := NewSeeded(1)
+			if _, err := games.Create("g", 4, gamelogic.ClassicRules()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := games.Join("g", "alice", "red"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := games.Join("g", "bob", ""); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := games.Start("g"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := games.Spend(routing.SpendRequest{Game: "g", Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := leave.fn(games); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := games.Treasury("g", "alice"); err != ErrNotInGame {
+				t.Errorf("Treasury() of a player who left: error = %v, want %v", err, ErrNotInGame)
+			}
+			game, err := games.Join("g", "alice", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if team := game.Teams["alice"]; team != "" {
+				t.Errorf("alice came back on team %q, want no team", team)
+			}
+			treasury, err := games.Treasury("g", "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if treasury.Gold != gamelogic.ClassicEconomy().StartingGold {
+				t.Errorf("alice came back with %d gold, want the starting %d", treasury.Gold, gamelogic.ClassicEconomy().StartingGold)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// ErrInvalidMove is returned for a move the server refuses to publish.
+// ErrUnitsLost is returned for a move of units the player no longer has,
+// e.g. they all fell in a war the player hadn't heard of yet, which is
+// stale rather than invalid.
+var (
+	ErrInvalidMove = errors.New("invalid move")
+	ErrUnitsLost   = errors.New("none of the units moved are in your army")
+)
+
+// validMove checks a move a player sent against what the server knows of
+// the game: it is running, the move was planned on moves the server sent,
+// and every unit can reach the destination from where the server last saw
+// it. The server's world is the only record of an army that counts, since
+// every unit in it was paid for: units it doesn't have are left behind. It
+// returns the move whole, with the player's army as the server knows it
+// after the move, without applying it. l.mu must be held.
+func (s *session) validMove(in gamelogic.MoveIntent) (gamelogic.ArmyMove, error) {
+	username := in.Username
+	if !slices.Contains(s.players, username) {
+		return gamelogic.ArmyMove{}, fmt.Errorf("%w: %s is not in %s", ErrInvalidMove, username, s.name)
+	}
+	if s.status != routing.GameStatusRunning || s.paused {
+		return gamelogic.ArmyMove{}, fmt.Errorf("%w: units can only move while the game is running and not paused", ErrInvalidMove)
+	}
+	if !s.rules.Map.Has(in.ToLocation) {
+		return gamelogic.ArmyMove{}, fmt.Errorf("%w: %s is not a valid location", ErrInvalidMove, in.ToLocation)
+	}
+	if len(in.UnitIDs) == 0 {
+		return gamelogic.ArmyMove{}, fmt.Errorf("%w: no units to move", ErrInvalidMove)
+	}
+	sent := 0
+	if army, ok := s.sent[username]; ok {
+		sent = army.Version()
+	}
+	if in.Base > sent {
+		return gamelogic.ArmyMove{}, fmt.Errorf("%w: planned on move %d, only %d were sent", ErrInvalidMove, in.Base, sent)
+	}
+
+	army, ok := s.world.Player(username)
+	if !ok {
+		army = gamelogic.Player{Username: username, Units: map[int]gamelogic.Unit{}}
+	}
+	moved := []gamelogic.Unit{}
+	for _, id := range in.UnitIDs {
+		unit, ok := army.Units[id]
+		if !ok || slices.ContainsFunc(moved, func(u gamelogic.Unit) bool { return u.ID == id }) {
+			continue
+		}
+		t, _ := s.rules.Catalog.Lookup(unit.Rank)
+		route := s.rules.Map.Route(unit.Location, in.ToLocation)
+		if unit.Location != in.ToLocation && (route == nil || len(route) > max(t.Movement, 1)) {
+			return gamelogic.ArmyMove{}, fmt.Errorf("%w: %s %d in %s can't reach %s in one move", ErrInvalidMove, unit.Rank, id, unit.Location, in.ToLocation)
+		}
+		unit.Location = in.ToLocation
+		army.Units[id] = unit
+		moved = append(moved, unit)
+	}
+	if len(moved) == 0 {
+		return gamelogic.ArmyMove{}, ErrUnitsLost
+	}
+	return gamelogic.ArmyMove{Player: army, Units: moved, ToLocation: in.ToLocation}, nil
+}
+
+// Move checks a move a player sent and applies it to the game. It returns
+// the move as the server publishes it, a delta between snapshots.
+func (l *Lobby) Move(name string, in gamelogic.MoveIntent) (gamelogic.ArmyMove, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return gamelogic.ArmyMove{}, ErrGameNotFound
+	}
+	whole, err := s.validMove(in)
+	if err != nil {
+		return gamelogic.ArmyMove{}, err
+	}
+	sent, ok := s.sent[whole.Player.Username]
+	if !ok {
+		sent = &gamelogic.SentArmy{}
+		s.sent[whole.Player.Username] = sent
+	}
+	stamped := sent.Stamp(whole)
+	whole.Version = stamped.Version
+	s.world.ApplyMove(whole)
+	return stamped, nil
+}
+
+// PublishIntent publishes a move a player sent the server. Games in turn
+// mode only play the moves ordered for a round, when it is resolved.
+func PublishIntent(pub pubsub.Publisher, games *Lobby, turns *Turns, name string, in gamelogic.MoveIntent, now time.Time) error {
+	if turns.Enabled(name) {
+		return rejectMove(pub, games, name, in, fmt.Errorf("%w: moves are ordered for a round in turn mode", ErrInvalidMove), now)
+	}
+	return publishMove(pub, games, name, in, now)
+}
+
+// publishMove checks a move and sends it to every player, or in fog of war
+// what each of them sees of it. Refused moves never reach the other
+// players, they are sent back to the mover and logged, unless they are
+// merely stale.
+func publishMove(pub pubsub.Publisher, games *Lobby, name string, in gamelogic.MoveIntent, now time.Time) error {
+	rules, err := games.Rules(name)
+	if err != nil {
+		return err
+	}
+	if rules.Fog {
+		err = RelayMove(pub, games, name, in, now)
+	} else {
+		var stamped gamelogic.ArmyMove
+		stamped, err = games.Move(name, in)
+		if err == nil {
+			return pub.PublishJSON(
+				routing.ExchangePerilTopic,
+				routing.GameKey(name, routing.ArmyMovesPrefix, in.Username),
+				stamped,
+			)
+		}
+	}
+	switch {
+	case errors.Is(err, ErrUnitsLost):
+		return returnMove(pub, games, name, in, err)
+	case errors.Is(err, ErrInvalidMove):
+		return rejectMove(pub, games, name, in, err, now)
+	}
+	return err
+}
+
+// rejectMove sends a move the server refused back to the mover and logs
+// it, and returns why.
+func rejectMove(pub pubsub.Publisher, games *Lobby, name string, in gamelogic.MoveIntent, reason error, now time.Time) error {
+	if err := returnMove(pub, games, name, in, reason); err != nil {
+		return err
+	}
+	if err := pub.PublishGob(
+		routing.ExchangePerilTopic,
+		routing.GameKey(name, routing.GameLogSlug, "server"),
+		routing.GameLog{
+			CurrentTime: now,
+			Message:     fmt.Sprintf("Rejected a move of %s: %v", in.Username, reason),
+			Username:    "server",
+		},
+	); err != nil {
+		return fmt.Errorf("couldn't log a rejected move of %s: %v", in.Username, err)
+	}
+	return reason
+}
+
+// returnMove sends a move the server didn't publish back to the mover,
+// with their army as the server knows it to undo the move with.
+func returnMove(pub pubsub.Publisher, games *Lobby, name string, in gamelogic.MoveIntent, reason error) error {
+	username := in.Username
+	armies, err := games.Armies(name)
+	if err != nil {
+		return err
+	}
+	army, ok := armies[username]
+	if !ok {
+		// Not a player of the game, so nobody to tell.
+		return nil
+	}
+	if err := pub.PublishJSON(
+		routing.ExchangePerilDirect,
+		routing.GameKey(name, routing.RejectionsKey, username),
+		gamelogic.Rejection{Move: in.Move(), Reason: reason.Error(), Army: army},
+	); err != nil {
+		return fmt.Errorf("couldn't return a move to %s: %v", username, err)
+	}
+	return nil
+}
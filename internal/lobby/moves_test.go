@@ -0,0 +1,122 @@
+package lobby
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name    string
+		game    string
+		in      gamelogic.MoveIntent
+		wantErr error
+	}{
+		{
+			name: "infantry to a neighbour",
+			game: "g",
+			in:   gamelogic.MoveIntent{Username: "alice", ToLocation: "europe", UnitIDs: []int{1}},
+		},
+		{
+			name: "cavalry two locations away",
+			game: "g",
+			in:   gamelogic.MoveIntent{Username: "alice", ToLocation: "americas", UnitIDs: []int{2}},
+		},
+		{
+			name: "units staying where they are",
+			game: "g",
+			in:   gamelogic.MoveIntent{Username: "alice", ToLocation: "asia", UnitIDs: []int{1, 2}},
+		},
+		{
+			name:    "infantry two locations away",
+			game:    "g",
+			in:      gamelogic.MoveIntent{Username: "alice", ToLocation: "americas", UnitIDs: []int{1, 2}},
+			wantErr: ErrInvalidMove,
+		},
+		{
+			name:    "unknown location",
+			game:    "g",
+			in:      gamelogic.MoveIntent{Username: "alice", ToLocation: "atlantis", UnitIDs: []int{1}},
+			wantErr: ErrInvalidMove,
+		},
+		{
+			name:    "no units",
+			game:    "g",
+			in:      gamelogic.MoveIntent{Username: "alice", ToLocation: "europe"},
+			wantErr: ErrInvalidMove,
+		},
+		{
+			name:    "planned on a move never sent",
+			game:    "g",
+			in:      gamelogic.MoveIntent{Username: "alice", ToLocation: "europe", UnitIDs: []int{1}, Base: 1},
+			wantErr: ErrInvalidMove,
+		},
+		{
+			name:    "units of another player",
+			game:    "g",
+			in:      gamelogic.MoveIntent{Username: "bob", ToLocation: "europe", UnitIDs: []int{1}},
+			wantErr: ErrUnitsLost,
+		},
+		{
+			name:    "not in the game",
+			game:    "g",
+			in:      gamelogic.MoveIntent{Username: "carol", ToLocation: "europe", UnitIDs: []int{1}},
+			wantErr: ErrInvalidMove,
+		},
+		{
+			name:    "unknown game",
+			game:    "h",
+			in:      gamelogic.MoveIntent{Username: "alice", ToLocation: "europe", UnitIDs: []int{1}},
+			wantErr: ErrGameNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games := runningGame(t)
+			for _, req := range []routing.SpendRequest{
+				{Game: "g", Username: "alice", UnitID: 1, Rank: "infantry", Location: "asia"},
+				{Game: "g", Username: "alice", UnitID: 2, Rank: "cavalry", Location: "asia"},
+			} {
+				if _, err := games.Spend(req); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mv, err := games.Move(tt.game, tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Move() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if mv.Version != 1 {
+				t.Errorf("Move() version = %d, want 1", mv.Version)
+			}
+			army, err := games.Army(tt.game, tt.in.Username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range tt.in.UnitIDs {
+				if got := army.Player.Units[id].Location; got != tt.in.ToLocation {
+					t.Errorf("unit %d is in %s, want %s", id, got, tt.in.ToLocation)
+				}
+			}
+		})
+	}
+}
+
+func TestMovePausedGame(t *testing.T) {
+	games := runningGame(t)
+	if _, err := games.Spend(routing.SpendRequest{Game: "g", Username: "alice", UnitID: 1, Rank: "infantry", Location: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := games.SetPaused("g", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := games.Move("g", gamelogic.MoveIntent{Username: "alice", ToLocation: "europe", UnitIDs: []int{1}}); !errors.Is(err, ErrInvalidMove) {
+		t.Errorf("Move() error = %v, want %v", err, ErrInvalidMove)
+	}
+}
@@ -0,0 +1,172 @@
+package lobby
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HeartbeatInterval = 5 * time.Second
+	PresenceTimeout   = 3 * HeartbeatInterval
+)
+
+var (
+	ErrUsernameTaken = errors.New("username is already taken by an online player")
+	ErrNotRegistered = errors.New("player is not registered")
+	ErrWrongToken    = errors.New("token doesn't match the player's registration")
+)
+
+type PlayerInfo struct {
+	Username string
+	Online   bool
+	LastSeen time.Time
+}
+
+// Registry knows the registered players. Every registration gets a secret
+// token, which only the client that registered knows, so no one else can
+// act for the player.
+type Registry struct {
+	players map[string]*PlayerInfo
+	tokens  map[string]string
+	mu      *sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		players: map[string]*PlayerInfo{},
+		tokens:  map[string]string{},
+		mu:      &sync.RWMutex{},
+	}
+}
+
+// Register claims username for a new client and returns its token.
+// Usernames of players that went offline can be reclaimed, so a crashed
+// client can come back under its name.
+func (r *Registry) Register(username string, now time.Time) (string, error) {
+	if err := validateUsername(username); err != nil {
+		return "", err
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.players[username]; ok && p.Online {
+		return "", ErrUsernameTaken
+	}
+	r.players[username] = &PlayerInfo{
+		Username: username,
+		Online:   true,
+		LastSeen: now,
+	}
+	r.tokens[username] = token
+	return token, nil
+}
+
+// Unregister frees username, for the client that registered it only.
+func (r *Registry) Unregister(username, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.authenticate(username, token); err != nil {
+		return err
+	}
+	delete(r.players, username)
+	delete(r.tokens, username)
+	return nil
+}
+
+// Authenticate checks that a request for username comes from the client
+// that registered it.
+func (r *Registry) Authenticate(username, token string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.authenticate(username, token)
+}
+
+// authenticate needs r.mu to be held.
+func (r *Registry) authenticate(username, token string) error {
+	if _, ok := r.players[username]; !ok {
+		return ErrNotRegistered
+	}
+	if token == "" || r.tokens[username] != token {
+		return ErrWrongToken
+	}
+	return nil
+}
+
+// Heartbeat refreshes a player's presence and reports whether the player
+// came back online.
+func (r *Registry) Heartbeat(username string, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.players[username]
+	if !ok {
+		return false, ErrNotRegistered
+	}
+	wasOnline := p.Online
+	p.Online = true
+	p.LastSeen = now
+	return !wasOnline, nil
+}
+
+// Sweep marks players whose last heartbeat is older than timeout as offline
+// and returns their usernames.
+func (r *Registry) Sweep(now time.Time, timeout time.Duration) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wentOffline := []string{}
+	for _, p := range r.players {
+		if p.Online && now.Sub(p.LastSeen) > timeout {
+			p.Online = false
+			wentOffline = append(wentOffline, p.Username)
+		}
+	}
+	sort.Strings(wentOffline)
+	return wentOffline
+}
+
+func (r *Registry) IsRegistered(username string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.players[username]
+	return ok
+}
+
+func (r *Registry) List() []PlayerInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	players := []PlayerInfo{}
+	for _, p := range r.players {
+		players = append(players, *p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Username < players[j].Username
+	})
+	return players
+}
+
+func validateUsername(username string) error {
+	if username == "" {
+		return errors.New("username can not be empty")
+	}
+	if strings.ContainsAny(username, ".*# ") {
+		return fmt.Errorf("username %q can not contain '.', '*', '#' or spaces", username)
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("couldn't generate a token: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
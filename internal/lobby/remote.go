@@ -0,0 +1,269 @@
+package lobby
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrRejected wraps every error the server sends back in a reply, as opposed
+// to errors talking to the broker.
+var ErrRejected = errors.New("rejected by the server")
+
+// RegisterPlayer registers username and returns the token that proves it
+// is this client's, e.g. to unregister.
+func RegisterPlayer(conn *amqp.Connection, username string) (string, error) {
+	resp, err := pubsub.RequestJSON[routing.PlayerRequest, routing.PlayerResponse](
+		conn,
+		routing.ExchangePerilDirect,
+		routing.PlayerRegisterKey,
+		routing.PlayerRequest{Username: username},
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return "", fmt.Errorf("couldn't register with the server: %v", err)
+	}
+	if resp.Error != "" {
+		return "", fmt.Errorf("%w: couldn't register as %s: %s", ErrRejected, username, resp.Error)
+	}
+	return resp.Token, nil
+}
+
+func UnregisterPlayer(conn *amqp.Connection, username, token string) error {
+	resp, err := pubsub.RequestJSON[routing.PlayerRequest, routing.PlayerResponse](
+		conn,
+		routing.ExchangePerilDirect,
+		routing.PlayerUnregisterKey,
+		routing.PlayerRequest{Username: username, Token: token},
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't unregister %s: %v", username, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("%w: couldn't unregister %s: %s", ErrRejected, username, resp.Error)
+	}
+	return nil
+}
+
+func ListGames(conn *amqp.Connection, username string) ([]routing.GameSummary, error) {
+	list, err := pubsub.RequestJSON[routing.LobbyRequest, routing.GameList](
+		conn,
+		routing.ExchangePerilDirect,
+		routing.LobbyListKey,
+		routing.LobbyRequest{Username: username},
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list games: %v", err)
+	}
+	return list.Games, nil
+}
+
+// Joined is what a player learns from joining a game.
+type Joined struct {
+	Game      routing.GameSummary
+	Rules     gamelogic.Rules
+	Treasury  routing.Treasury
+	Diplomacy routing.DiplomacyState
+}
+
+// JoinGame joins a game, on a team unless team is empty, and returns the
+// rules it is played by.
+func JoinGame(conn *amqp.Connection, username, token, game, team string) (Joined, error) {
+	resp, err := lobbyRequest(conn, routing.LobbyJoinKey, routing.LobbyRequest{Game: game, Username: username, Token: token, Team: team}, "join")
+	if err != nil {
+		return Joined{}, err
+	}
+	rules, err := decodeRules(resp.Rules)
+	if err != nil {
+		return Joined{}, err
+	}
+	return Joined{Game: resp.Game, Rules: rules, Treasury: resp.Treasury, Diplomacy: resp.Diplomacy}, nil
+}
+
+func LeaveGame(conn *amqp.Connection, username, token, game string) (routing.GameSummary, error) {
+	resp, err := lobbyRequest(conn, routing.LobbyLeaveKey, routing.LobbyRequest{Game: game, Username: username, Token: token}, "leave")
+	return resp.Game, err
+}
+
+func lobbyRequest(conn *amqp.Connection, key string, req routing.LobbyRequest, action string) (routing.LobbyResponse, error) {
+	resp, err := pubsub.RequestJSON[routing.LobbyRequest, routing.LobbyResponse](
+		conn,
+		routing.ExchangePerilDirect,
+		key,
+		req,
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return routing.LobbyResponse{}, fmt.Errorf("couldn't %s %s: %v", action, req.Game, err)
+	}
+	if resp.Error != "" {
+		return routing.LobbyResponse{}, fmt.Errorf("%w: couldn't %s %s: %s", ErrRejected, action, req.Game, resp.Error)
+	}
+	return resp, nil
+}
+
+// FetchRules asks the server for the rules of a game without joining it.
+func FetchRules(conn *amqp.Connection, username, game string) (gamelogic.Rules, error) {
+	resp, err := pubsub.RequestJSON[routing.LobbyRequest, routing.RulesResponse](
+		conn,
+		routing.ExchangePerilDirect,
+		routing.LobbyRulesKey,
+		routing.LobbyRequest{Game: game, Username: username},
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return gamelogic.Rules{}, fmt.Errorf("couldn't fetch the rules of %s: %v", game, err)
+	}
+	if resp.Error != "" {
+		return gamelogic.Rules{}, fmt.Errorf("%w: couldn't fetch the rules of %s: %s", ErrRejected, game, resp.Error)
+	}
+	return decodeRules(resp.Rules)
+}
+
+func decodeRules(data []byte) (gamelogic.Rules, error) {
+	rules, err := gamelogic.ParseRules(data)
+	if err != nil {
+		return gamelogic.Rules{}, fmt.Errorf("server sent invalid rules: %v", err)
+	}
+	return rules, nil
+}
+
+// EncodeRules is how the server puts rules in its replies.
+func EncodeRules(r gamelogic.Rules) (json.RawMessage, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal rules: %v", err)
+	}
+	return data, nil
+}
+
+// FetchState asks the server for a game's current pause and round state.
+// Callers should bind their pause and round queues first so no later change
+// can be missed.
+func FetchState(conn *amqp.Connection, username, game string) (routing.PlayingState, routing.RoundState, error) {
+	resp, err := pubsub.RequestJSON[routing.LobbyRequest, routing.StateResponse](
+		conn,
+		routing.ExchangePerilDirect,
+		routing.LobbyStateKey,
+		routing.LobbyRequest{Game: game, Username: username},
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return routing.PlayingState{}, routing.RoundState{}, fmt.Errorf("couldn't fetch the state of %s: %v", game, err)
+	}
+	if resp.Error != "" {
+		return routing.PlayingState{}, routing.RoundState{}, fmt.Errorf("%w: couldn't fetch the state of %s: %s", ErrRejected, game, resp.Error)
+	}
+	return resp.State, resp.Round, nil
+}
+
+// RemoteBank pays for spawns by asking the server, with the token the
+// player registered with.
+type RemoteBank struct {
+	Conn     *amqp.Connection
+	Game     string
+	Username string
+	Token    string
+}
+
+func (b RemoteBank) Spend(unit gamelogic.Unit) (routing.Treasury, error) {
+	req := spendRequest(b.Game, b.Username, unit)
+	req.Token = b.Token
+	resp, err := pubsub.RequestJSON[routing.SpendRequest, routing.SpendResponse](
+		b.Conn,
+		routing.ExchangePerilDirect,
+		routing.TreasurySpendKey,
+		req,
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return routing.Treasury{}, fmt.Errorf("couldn't pay for the %s: %v", unit.Rank, err)
+	}
+	if resp.Error != "" {
+		return routing.Treasury{}, fmt.Errorf("%w: couldn't pay for the %s: %s", ErrRejected, unit.Rank, resp.Error)
+	}
+	return resp.Treasury, nil
+}
+
+// RemoteArmies resyncs armies by asking the server.
+type RemoteArmies struct {
+	Conn     *amqp.Connection
+	Game     string
+	Username string
+}
+
+func (a RemoteArmies) Army(username string) (gamelogic.ArmyMove, error) {
+	resp, err := pubsub.RequestJSON[routing.ArmyRequest, routing.ArmyResponse](
+		a.Conn,
+		routing.ExchangePerilDirect,
+		routing.ArmyRequestKey,
+		routing.ArmyRequest{Game: a.Game, Username: a.Username, Of: username},
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return gamelogic.ArmyMove{}, fmt.Errorf("couldn't fetch the army of %s: %v", username, err)
+	}
+	if resp.Error != "" {
+		return gamelogic.ArmyMove{}, fmt.Errorf("%w: couldn't fetch the army of %s: %s", ErrRejected, username, resp.Error)
+	}
+	army := gamelogic.ArmyMove{}
+	if err := json.Unmarshal(resp.Army, &army); err != nil {
+		return gamelogic.ArmyMove{}, fmt.Errorf("server sent an invalid army: %v", err)
+	}
+	return army, nil
+}
+
+// Negotiate sends a diplomacy request to the server and returns the pacts
+// of the game afterwards.
+func Negotiate(conn *amqp.Connection, req routing.DiplomacyRequest) (routing.DiplomacyState, error) {
+	resp, err := pubsub.RequestJSON[routing.DiplomacyRequest, routing.DiplomacyResponse](
+		conn,
+		routing.ExchangePerilDirect,
+		routing.DiplomacyRequestKey,
+		req,
+		pubsub.DefaultRequestTimeout,
+	)
+	if err != nil {
+		return routing.DiplomacyState{}, fmt.Errorf("couldn't %s with %s: %v", req.Action, req.With, err)
+	}
+	if resp.Error != "" {
+		return routing.DiplomacyState{}, fmt.Errorf("%w: couldn't %s with %s: %s", ErrRejected, req.Action, req.With, resp.Error)
+	}
+	return resp.State, nil
+}
+
+// SendHeartbeats publishes a heartbeat for username every HeartbeatInterval
+// until stop is closed.
+func SendHeartbeats(conn *amqp.Connection, username string, stop <-chan struct{}) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("couldn't open heartbeat channel: %v", err)
+	}
+	defer ch.Close()
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := pubsub.PublishJSON(
+				ch,
+				routing.ExchangePerilTopic,
+				routing.PresencePrefix+"."+username,
+				routing.Heartbeat{Username: username, SentAt: now},
+			); err != nil {
+				return fmt.Errorf("couldn't send heartbeat: %v", err)
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const DefaultRoundDuration = 60 * time.Second
+
+var ErrTurnsDisabled = errors.New("turn mode is not enabled for this game")
+
+type round struct {
+	// stopped is closed when turn mode is turned off, so the game's rounds
+	// stop being run.
+	stopped  chan struct{}
+	duration time.Duration
+	number   int
+	open     bool
+	deadline time.Time
+	orders   map[string]gamelogic.OrderSet
+}
+
+func (r *round) state() routing.RoundState {
+	return routing.RoundState{
+		TurnBased: true,
+		Round:     r.number,
+		Open:      r.open,
+		Deadline:  r.deadline,
+	}
+}
+
+type Turns struct {
+	rounds map[string]*round
+	mu     *sync.Mutex
+}
+
+func NewTurns() *Turns {
+	return &Turns{
+		rounds: map[string]*round{},
+		mu:     &sync.Mutex{},
+	}
+}
+
+// Enable turns on turn mode for a game. The returned channel is closed when
+// it is turned off again, which is when whoever runs the rounds must stop.
+func (t *Turns) Enable(game string, duration time.Duration) (<-chan struct{}, error) {
+	if duration <= 0 {
+		return nil, errors.New("round duration must be positive")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rounds[game]; ok {
+		return nil, errors.New("turn mode is already enabled for this game")
+	}
+	r := &round{stopped: make(chan struct{}), duration: duration}
+	t.rounds[game] = r
+	return r.stopped, nil
+}
+
+func (t *Turns) Disable(game string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[game]
+	if !ok {
+		return ErrTurnsDisabled
+	}
+	close(r.stopped)
+	delete(t.rounds, game)
+	return nil
+}
+
+func (t *Turns) Enabled(game string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.rounds[game]
+	return ok
+}
+
+func (t *Turns) Current(game string) (routing.RoundState, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[game]
+	if !ok {
+		return routing.RoundState{}, false
+	}
+	return r.state(), true
+}
+
+// Open starts the next round of a game and returns its announcement.
+func (t *Turns) Open(game string, now time.Time) (routing.RoundState, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[game]
+	if !ok {
+		return routing.RoundState{}, ErrTurnsDisabled
+	}
+	if r.open {
+		return routing.RoundState{}, fmt.Errorf("round %d is still open", r.number)
+	}
+	r.number++
+	r.open = true
+	r.deadline = now.Add(r.duration)
+	r.orders = map[string]gamelogic.OrderSet{}
+	return r.state(), nil
+}
+
+// Submit records a player's orders for the open round. Every player gets a
+// single order set per round.
+func (t *Turns) Submit(orders gamelogic.OrderSet) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[orders.Game]
+	if !ok {
+		return ErrTurnsDisabled
+	}
+	if !r.open || orders.Round != r.number {
+		return fmt.Errorf("round %d is not open", orders.Round)
+	}
+	if _, ok := r.orders[orders.Username]; ok {
+		return fmt.Errorf("%s already submitted orders for round %d", orders.Username, r.number)
+	}
+	r.orders[orders.Username] = orders
+	return nil
+}
+
+// Ready reports whether the open round can be closed, either because its
+// deadline passed or because every player has submitted their orders.
+func (t *Turns) Ready(game string, players []string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[game]
+	if !ok || !r.open {
+		return false
+	}
+	if !now.Before(r.deadline) {
+		return true
+	}
+	for _, username := range players {
+		if _, ok := r.orders[username]; !ok {
+			return false
+		}
+	}
+	return len(players) > 0
+}
+
+// Close ends the open round and returns the collected order sets sorted by
+// username, so every resolution of a round publishes moves in the same order.
+func (t *Turns) Close(game string) (routing.RoundState, []gamelogic.OrderSet, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[game]
+	if !ok {
+		return routing.RoundState{}, nil, ErrTurnsDisabled
+	}
+	if !r.open {
+		return routing.RoundState{}, nil, fmt.Errorf("round %d is not open", r.number)
+	}
+	r.open = false
+
+	orders := []gamelogic.OrderSet{}
+	for _, set := range r.orders {
+		orders = append(orders, set)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Username < orders[j].Username
+	})
+	return r.state(), orders, nil
+}
+
+// Advance opens or resolves a round of a game if it is due at now. It
+// reports false once the game ended or turn mode was turned off, so the
+// caller can stop polling.
+func (t *Turns) Advance(pub pubsub.Publisher, games *Lobby, name string, now time.Time) (bool, error) {
+	game, ok := games.Get(name)
+	if !ok || game.Status == routing.GameStatusEnded || !t.Enabled(name) {
+		return false, nil
+	}
+
+	current, _ := t.Current(name)
+	switch {
+	case current.Open && t.Ready(name, game.Players, now):
+		if err := t.resolve(pub, games, name, now); err != nil {
+			return true, fmt.Errorf("couldn't resolve round %d: %v", current.Round, err)
+		}
+	case !current.Open && game.Status == routing.GameStatusRunning && !game.Paused:
+		rs, err := t.Open(name, now)
+		if err != nil {
+			return true, fmt.Errorf("couldn't open a round: %v", err)
+		}
+		if err := pub.PublishJSON(routing.ExchangePerilDirect, routing.GameKey(name, routing.RoundKey), rs); err != nil {
+			return true, fmt.Errorf("couldn't announce round %d: %v", rs.Round, err)
+		}
+	}
+	return true, nil
+}
+
+// resolve closes the open round and publishes every collected move at once,
+// so all players see the round's moves (and start its wars) together. In fog
+// of war, they only see what is in sight of their units. Moves the server
+// refuses are logged and left out. Then
+// players are paid for where their armies ended up, and the game is judged.
+func (t *Turns) resolve(pub pubsub.Publisher, games *Lobby, name string, now time.Time) error {
+	rs, orders, err := t.Close(name)
+	if err != nil {
+		return err
+	}
+	if err := pub.PublishJSON(routing.ExchangePerilDirect, routing.GameKey(name, routing.RoundKey), rs); err != nil {
+		return err
+	}
+
+	moves := 0
+	for _, set := range orders {
+		for _, mv := range set.Moves {
+			if err := publishMove(pub, games, name, mv, now); err != nil {
+				if errors.Is(err, ErrInvalidMove) {
+					continue
+				}
+				return err
+			}
+			moves++
+		}
+	}
+	if err := PayIncome(pub, games, name); err != nil {
+		return err
+	}
+
+	if err := pub.PublishGob(
+		routing.ExchangePerilTopic,
+		routing.GameKey(name, routing.GameLogSlug, "server"),
+		routing.GameLog{
+			CurrentTime: now,
+			Message:     fmt.Sprintf("Round %d resolved with %d move(s) from %d player(s)", rs.Round, moves, len(orders)),
+			Username:    "server",
+		},
+	); err != nil {
+		return err
+	}
+	_, err = JudgeGame(pub, games, name, now)
+	return err
+}
@@ -0,0 +1,230 @@
+package lobby
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// SetTeam puts a player of a game on a team, or on none when team is
+// empty. Teams are picked before the game starts.
+func (l *Lobby) SetTeam(name, username, team string) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.GameSummary{}, ErrGameNotFound
+	}
+	if !slices.Contains(s.players, username) {
+		return routing.GameSummary{}, ErrNotInGame
+	}
+	if s.teams[username] == team {
+		return s.summary(), nil
+	}
+	if err := s.checkTeam(username, team); err != nil {
+		return routing.GameSummary{}, err
+	}
+	if team == "" {
+		delete(s.teams, username)
+	} else {
+		s.teams[username] = team
+	}
+	return s.summary(), nil
+}
+
+// checkTeam reports why username can't move to team, if they can't. l.mu
+// must be held.
+func (s *session) checkTeam(username, team string) error {
+	if s.status != routing.GameStatusWaiting {
+		return fmt.Errorf("game is %s, teams can only be picked before it starts", s.status)
+	}
+	if err := validateTeamName(team); err != nil {
+		return err
+	}
+	if team == username || slices.Contains(s.players, team) {
+		return fmt.Errorf("team %s can't be named after a player", team)
+	}
+	return nil
+}
+
+// currentTeams returns the teams of the players still in the game. l.mu
+// must be held.
+func (s *session) currentTeams() map[string]string {
+	teams := map[string]string{}
+	for _, username := range s.players {
+		if team, ok := s.teams[username]; ok {
+			teams[username] = team
+		}
+	}
+	return teams
+}
+
+// side is the team of a player, or the player when on no team. l.mu must
+// be held.
+func (s *session) side(username string) string {
+	if team := s.teams[username]; team != "" {
+		return team
+	}
+	return username
+}
+
+// Judge checks the victory conditions of a game, and ends it when a side
+// has won. Games are judged after every payday, which is what a round of
+// holding territories means outside of turn mode too.
+func (l *Lobby) Judge(name string) (routing.GameOver, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return routing.GameOver{}, false, ErrGameNotFound
+	}
+	if s.status != routing.GameStatusRunning || !s.rules.Victory.Enabled() {
+		return routing.GameOver{}, false, nil
+	}
+	winner, reason, ok := s.judge()
+	if !ok {
+		return routing.GameOver{}, false, nil
+	}
+	s.status = routing.GameStatusEnded
+	s.setPaused(true)
+
+	teams := s.currentTeams()
+	over := routing.GameOver{
+		Game:       s.name,
+		Winners:    winner.Players,
+		Team:       winner.Team,
+		Reason:     reason,
+		Scoreboard: s.rules.Scoreboard(s.world, s.players, teams, winner.Players),
+	}
+	for i, score := range over.Scoreboard {
+		over.Scoreboard[i].Gold = s.treasury(score.Username).Gold
+	}
+	return over, true, nil
+}
+
+// judge returns the side that met a victory condition, if any. It also
+// counts the rounds every side has held enough territories. l.mu must be
+// held.
+func (s *session) judge() (gamelogic.Standing, string, bool) {
+	v := s.rules.Victory
+	standings := s.rules.Standings(s.world, s.players, s.currentTeams())
+	for _, st := range standings {
+		if st.Units > 0 {
+			s.fielded[st.Side()] = true
+		}
+	}
+
+	if v.Territories > 0 {
+		rounds := max(v.HoldRounds, 1)
+		best := -1
+		for i, st := range standings {
+			if st.Territories < v.Territories {
+				s.held[st.Side()] = 0
+				continue
+			}
+			s.held[st.Side()]++
+			if s.held[st.Side()] >= rounds && (best == -1 || st.Territories > standings[best].Territories) {
+				best = i
+			}
+		}
+		if best != -1 {
+			st := standings[best]
+			return st, fmt.Sprintf("held %d territories for %d round(s)", st.Territories, s.held[st.Side()]), true
+		}
+	}
+
+	if v.Elimination {
+		alive := []gamelogic.Standing{}
+		for _, st := range standings {
+			if st.Units > 0 {
+				alive = append(alive, st)
+			}
+		}
+		// A side that never had units hasn't been eliminated, it hasn't
+		// played yet.
+		if len(alive) == 1 && len(s.fielded) > 1 {
+			return alive[0], "eliminated every other side", true
+		}
+	}
+
+	if v.RoundLimit > 0 && s.payday >= v.RoundLimit && len(standings) > 0 {
+		best := []gamelogic.Standing{standings[0]}
+		for _, st := range standings[1:] {
+			switch {
+			case st.Beats(best[0]):
+				best = []gamelogic.Standing{st}
+			case !best[0].Beats(st):
+				best = append(best, st)
+			}
+		}
+		if len(best) == 1 {
+			return best[0], fmt.Sprintf("had the best score after %d round(s)", s.payday), true
+		}
+		// Sides tied for the best score share the win.
+		tied := gamelogic.Standing{Players: []string{}}
+		for _, st := range best {
+			tied.Players = append(tied.Players, st.Players...)
+		}
+		sort.Strings(tied.Players)
+		return tied, fmt.Sprintf("tied for the best score after %d round(s)", s.payday), true
+	}
+	return gamelogic.Standing{}, "", false
+}
+
+// JudgeGame judges a game. When it is over, it announces the winners to
+// every player, pauses the game so no more moves are made, and writes the
+// final scoreboard to the game log.
+func JudgeGame(pub pubsub.Publisher, games *Lobby, name string, now time.Time) (bool, error) {
+	over, ok, err := games.Judge(name)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := pub.PublishJSON(
+		routing.ExchangePerilDirect,
+		routing.GameKey(name, routing.GameOverKey),
+		over,
+	); err != nil {
+		return true, fmt.Errorf("couldn't announce the end of %s: %v", name, err)
+	}
+	if err := PublishPause(pub, games, name); err != nil {
+		return true, fmt.Errorf("couldn't pause %s: %v", name, err)
+	}
+	if err := pub.PublishGob(
+		routing.ExchangePerilTopic,
+		routing.GameKey(name, routing.GameLogSlug, "server"),
+		routing.GameLog{
+			CurrentTime: now,
+			Message:     GameOverSummary(over),
+			Username:    "server",
+		},
+	); err != nil {
+		return true, fmt.Errorf("couldn't log the end of %s: %v", name, err)
+	}
+	return true, nil
+}
+
+// GameOverSummary is the line logged about the end of a game.
+func GameOverSummary(over routing.GameOver) string {
+	winner := strings.Join(over.Winners, ", ")
+	if over.Team != "" {
+		winner = fmt.Sprintf("team %s (%s)", over.Team, winner)
+	}
+	scores := []string{}
+	for i, score := range over.Scoreboard {
+		scores = append(scores, fmt.Sprintf("%d. %s %d/%d/%d", i+1, score.Username, score.Territories, score.Units, score.Power))
+	}
+	return fmt.Sprintf("Game %s is over, %s won: %s. Territories/units/power: %s", over.Game, winner, over.Reason, strings.Join(scores, ", "))
+}
+
+func validateTeamName(team string) error {
+	if err := validateGameName(team); err != nil && team != "" {
+		return fmt.Errorf("invalid team name: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package lobby
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestJudge(t *testing.T) {
+	tests := []struct {
+		name        string
+		victory     gamelogic.Victory
+		teams       map[string]string
+		spawns      []routing.SpendRequest
+		war         *gamelogic.WarDeclaration
+		wantRound   int
+		wantWinners []string
+		wantTeam    string
+	}{
+		{
+			name:    "no victory conditions",
+			victory: gamelogic.Victory{},
+			spawns: []routing.SpendRequest{
+				{Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"},
+			},
+		},
+		{
+			name:    "territories held for enough rounds",
+			victory: gamelogic.Victory{Territories: 2, HoldRounds: 2},
+			spawns: []routing.SpendRequest{
+				{Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"},
+				{Username: "alice", UnitID: 2, Rank: "infantry", Location: "asia"},
+				{Username: "bob", UnitID: 1, Rank: "infantry", Location: "americas"},
+			},
+			wantRound:   2,
+			wantWinners: []string{"alice"},
+		},
+		{
+			name:    "territories held by a team",
+			victory: gamelogic.Victory{Territories: 2},
+			teams:   map[string]string{"alice": "red", "bob": "red", "carol": "blue"},
+			spawns: []routing.SpendRequest{
+				{Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"},
+				{Username: "bob", UnitID: 1, Rank: "infantry", Location: "asia"},
+				{Username: "carol", UnitID: 1, Rank: "infantry", Location: "americas"},
+			},
+			wantRound:   1,
+			wantWinners: []string{"alice", "bob"},
+			wantTeam:    "red",
+		},
+		{
+			name:    "not enough territories",
+			victory: gamelogic.Victory{Territories: 3},
+			spawns: []routing.SpendRequest{
+				{Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"},
+				{Username: "alice", UnitID: 2, Rank: "infantry", Location: "asia"},
+			},
+		},
+		{
+			name:    "elimination",
+			victory: gamelogic.Victory{Elimination: true},
+			spawns: []routing.SpendRequest{
+				{Username: "alice", UnitID: 1, Rank: "artillery", Location: "asia"},
+				{Username: "bob", UnitID: 1, Rank: "infantry", Location: "asia"},
+			},
+			war:         &gamelogic.WarDeclaration{Attacker: "alice", Defender: "bob", Location: "asia"},
+			wantRound:   1,
+			wantWinners: []string{"alice"},
+		},
+		{
+			name:    "elimination before the others played",
+			victory: gamelogic.Victory{Elimination: true},
+			spawns: []routing.SpendRequest{
+				{Username: "alice", UnitID: 1, Rank: "infantry", Location: "asia"},
+			},
+		},
+		{
+			name:    "best score at the round limit",
+			victory: gamelogic.Victory{RoundLimit: 2},
+			spawns: []routing.SpendRequest{
+				{Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"},
+				{Username: "alice", UnitID: 2, Rank: "infantry", Location: "asia"},
+				{Username: "bob", UnitID: 1, Rank: "infantry", Location: "americas"},
+			},
+			wantRound:   2,
+			wantWinners: []string{"alice"},
+		},
+		{
+			name:    "tie at the round limit",
+			victory: gamelogic.Victory{RoundLimit: 1},
+			spawns: []routing.SpendRequest{
+				{Username: "alice", UnitID: 1, Rank: "infantry", Location: "europe"},
+				{Username: "bob", UnitID: 1, Rank: "infantry", Location: "americas"},
+			},
+			wantRound:   1,
+			wantWinners: []string{"alice", "bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := gamelogic.ClassicRules()
+			rules.Victory = tt.victory
+			games := NewSeeded(1)
+			if _, err := games.Create("g", 4, rules); err != nil {
+				t.Fatal(err)
+			}
+			players := []string{"alice", "bob"}
+			if tt.teams != nil {
+				players = append(players, "carol")
+			}
+			for _, username := range players {
+				if _, err := games.Join("g", username, tt.teams[username]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := games.Start("g"); err != nil {
+				t.Fatal(err)
+			}
+			for _, req := range tt.spawns {
+				req.Game = "g"
+				if _, err := games.Spend(req); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.war != nil {
+				if _, _, err := games.War("g", *tt.war); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for round := 1; round <= 3; round++ {
+				if _, err := games.Payday("g"); err != nil {
+					t.Fatal(err)
+				}
+				over, ok, err := games.Judge("g")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					continue
+				}
+				if round != tt.wantRound {
+					t.Fatalf("game ended in round %d, want %d", round, tt.wantRound)
+				}
+				if !slices.Equal(over.Winners, tt.wantWinners) || over.Team != tt.wantTeam {
+					t.Errorf("Judge() winners = %v of team %q, want %v of team %q", over.Winners, over.Team, tt.wantWinners, tt.wantTeam)
+				}
+				if game, _ := games.Get("g"); game.Status != routing.GameStatusEnded {
+					t.Errorf("game is %s after it was won", game.Status)
+				}
+				return
+			}
+			if tt.wantRound != 0 {
+				t.Errorf("game didn't end, want it won in round %d", tt.wantRound)
+			}
+		})
+	}
+}
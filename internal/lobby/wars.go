@@ -0,0 +1,138 @@
+package lobby
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// War fights the war a defender declared with the armies of the game as
+// the server knows them, never the ones a player claims, and applies it to
+// the game the way every player will fight it. It seeds the dice, and
+// keeps only the units fighting. It returns the war and the players who
+// see it: everyone, or in fog of war the players who can see a location
+// it is fought in, its sides included. Moves are applied when the server
+// checks them, see Move.
+func (l *Lobby) War(name string, decl gamelogic.WarDeclaration) (gamelogic.RecognitionOfWar, []string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[name]
+	if !ok {
+		return gamelogic.RecognitionOfWar{}, nil, ErrGameNotFound
+	}
+	for _, username := range []string{decl.Defender, decl.Attacker} {
+		if !slices.Contains(s.players, username) {
+			return gamelogic.RecognitionOfWar{}, nil, fmt.Errorf("%w: %s can't go to war", ErrNotInGame, username)
+		}
+	}
+	if decl.Attacker == decl.Defender {
+		return gamelogic.RecognitionOfWar{}, nil, fmt.Errorf("%s can't go to war with themselves", decl.Defender)
+	}
+	if kind, ok := s.pactBetween(decl.Attacker, decl.Defender); ok {
+		return gamelogic.RecognitionOfWar{}, nil, fmt.Errorf("%s and %s have a %s pact", decl.Attacker, decl.Defender, kind)
+	}
+	attacker, _ := s.world.Player(decl.Attacker)
+	defender, _ := s.world.Player(decl.Defender)
+	if !occupies(attacker, decl.Location) || !occupies(defender, decl.Location) {
+		return gamelogic.RecognitionOfWar{}, nil, fmt.Errorf("%s and %s don't both have units in %s", decl.Attacker, decl.Defender, decl.Location)
+	}
+
+	rw := gamelogic.RecognitionOfWar{
+		Attacker: attacker,
+		Defender: defender,
+		Allies:   s.allies(decl.Defender),
+		Seed:     l.rng.Int63(),
+	}.Contested()
+	viewers := s.viewers(rw)
+	s.world.ApplyWar(rw)
+	return rw, viewers, nil
+}
+
+// viewers returns the players who see a war before it is fought. l.mu must
+// be held.
+func (s *session) viewers(rw gamelogic.RecognitionOfWar) []string {
+	if !s.rules.Fog {
+		return slices.Clone(s.players)
+	}
+	viewers := []string{}
+	for _, username := range s.players {
+		p, _ := s.world.Player(username)
+		sight := s.rules.Map.Sight(p)
+		for _, unit := range rw.Defender.Units {
+			if slices.Contains(sight, unit.Location) {
+				viewers = append(viewers, username)
+				break
+			}
+		}
+	}
+	return viewers
+}
+
+// pactBetween returns the pact two players of the game have, if any.
+// Teammates always have a team pact. l.mu must be held.
+func (s *session) pactBetween(a, b string) (routing.PactKind, bool) {
+	if team := s.teams[a]; team != "" && s.teams[b] == team {
+		return routing.PactTeam, true
+	}
+	kind, ok := s.pacts[pactPair(a, b)]
+	return kind, ok
+}
+
+// allies returns the armies of the players who defend alongside username:
+// their teammates and allies, but not the players they only have a truce
+// with. l.mu must be held.
+func (s *session) allies(username string) []gamelogic.Player {
+	allies := []gamelogic.Player{}
+	for _, p := range s.world.Players() {
+		if p.Username == username || !slices.Contains(s.players, p.Username) {
+			continue
+		}
+		if kind, ok := s.pactBetween(username, p.Username); ok && kind != routing.PactTruce {
+			allies = append(allies, p)
+		}
+	}
+	return allies
+}
+
+func occupies(p gamelogic.Player, loc gamelogic.Location) bool {
+	for _, unit := range p.Units {
+		if unit.Location == loc {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishWar sends a war a defender declared to every player, who each
+// fight it and lose their own units, or in fog of war to the players who
+// see it only.
+func PublishWar(pub pubsub.Publisher, games *Lobby, name string, decl gamelogic.WarDeclaration) error {
+	rules, err := games.Rules(name)
+	if err != nil {
+		return err
+	}
+	rw, viewers, err := games.War(name, decl)
+	if err != nil {
+		return err
+	}
+	if !rules.Fog {
+		return pub.PublishJSON(
+			routing.ExchangePerilDirect,
+			routing.GameKey(name, routing.WarRecognitionsPrefix),
+			rw,
+		)
+	}
+	for _, viewer := range viewers {
+		if err := pub.PublishJSON(
+			routing.ExchangePerilDirect,
+			routing.GameKey(name, routing.WarRecognitionsPrefix, viewer),
+			rw,
+		); err != nil {
+			return fmt.Errorf("couldn't tell %s about a war of %s: %v", viewer, decl.Defender, err)
+		}
+	}
+	return nil
+}
@@ -15,6 +15,7 @@ type SimpleQueueType int
 const (
 	QueueTypeDurable SimpleQueueType = iota
 	QueueTypeTransient
+	QueueTypeShared
 )
 
 type AckType int
@@ -51,21 +52,34 @@ func DeclareAndBind(
 	if err != nil {
 		return nil, amqp.Queue{}, err
 	}
+	queue, err := declareAndBindOn(ch, exchange, queueName, key, queueType)
+	if err != nil {
+		return nil, amqp.Queue{}, err
+	}
+	return ch, queue, nil
+}
 
-	durable := queueType == 0
-	autoDelete := queueType == 1
-	exclusive := queueType == 1
+func declareAndBindOn(
+	ch *amqp.Channel,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+) (amqp.Queue, error) {
+	durable := queueType == QueueTypeDurable
+	autoDelete := queueType == QueueTypeTransient || queueType == QueueTypeShared
+	exclusive := queueType == QueueTypeTransient
 
 	queue, err := ch.QueueDeclare(queueName, durable, autoDelete, exclusive, false, amqp.Table{"x-dead-letter-exchange": "peril_dlx"})
 	if err != nil {
-		return nil, amqp.Queue{}, err
+		return amqp.Queue{}, err
 	}
 
 	if err = ch.QueueBind(queueName, key, exchange, false, nil); err != nil {
-		return nil, amqp.Queue{}, err
+		return amqp.Queue{}, err
 	}
 
-	return ch, queue, nil
+	return queue, nil
 }
 
 func SubscribeJSON[T any](
@@ -83,16 +97,66 @@ func SubscribeJSON[T any](
 		key,
 		queueType,
 		handler,
-		func(val []byte) (T, error) {
-			var message T
-			if err := json.Unmarshal(val, &message); err != nil {
-				return message, err
-			}
-			return message, nil
-		},
+		unmarshalJSON[T],
 	)
 }
 
+// SubscribeJSONOn is SubscribeJSON on a channel the caller owns. Closing
+// the channel stops every subscription made on it.
+func SubscribeJSONOn[T any](
+	ch *amqp.Channel,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	handler func(T) AckType,
+) error {
+	return consume(ch, exchange, queueName, key, queueType, withoutKey(handler), unmarshalJSON[T])
+}
+
+// SubscribeJSONKeyed is SubscribeJSON for handlers that need the routing
+// key each message was published with, e.g. to tell who sent it.
+func SubscribeJSONKeyed[T any](
+	conn *amqp.Connection,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	handler func(string, T) AckType,
+) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	return consume(ch, exchange, queueName, key, queueType, handler, unmarshalJSON[T])
+}
+
+// SubscribeJSONKeyedOn is SubscribeJSONKeyed on a channel the caller owns.
+func SubscribeJSONKeyedOn[T any](
+	ch *amqp.Channel,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	handler func(string, T) AckType,
+) error {
+	return consume(ch, exchange, queueName, key, queueType, handler, unmarshalJSON[T])
+}
+
+func withoutKey[T any](handler func(T) AckType) func(string, T) AckType {
+	return func(_ string, message T) AckType {
+		return handler(message)
+	}
+}
+
+func unmarshalJSON[T any](val []byte) (T, error) {
+	var message T
+	if err := json.Unmarshal(val, &message); err != nil {
+		return message, err
+	}
+	return message, nil
+}
+
 func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T) error {
 	var buf bytes.Buffer
 	encoder := gob.NewEncoder(&buf)
@@ -146,7 +210,23 @@ func subscribe[T any](
 	handler func(T) AckType,
 	unmarshaller func([]byte) (T, error),
 ) error {
-	ch, queue, err := DeclareAndBind(conn, exchange, queueName, key, queueType)
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	return consume(ch, exchange, queueName, key, queueType, withoutKey(handler), unmarshaller)
+}
+
+func consume[T any](
+	ch *amqp.Channel,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	handler func(string, T) AckType,
+	unmarshaller func([]byte) (T, error),
+) error {
+	queue, err := declareAndBindOn(ch, exchange, queueName, key, queueType)
 	if err != nil {
 		return err
 	}
@@ -167,16 +247,13 @@ func subscribe[T any](
 				fmt.Printf("Error unmarshaling message: %v", err)
 				continue
 			}
-			switch handler(message) {
+			switch handler(delivery.RoutingKey, message) {
 			case AckTypeAck:
 				delivery.Ack(false)
-				fmt.Println("Ack")
 			case AckTypeNackRequeue:
 				delivery.Nack(false, true)
-				fmt.Println("NackRequeue")
 			case AckTypeNackDiscard:
 				delivery.Nack(false, false)
-				fmt.Println("NackDiscard")
 			}
 		}
 	}()
@@ -0,0 +1,24 @@
+package pubsub
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Publisher is what game code publishes through, so the same code can run
+// against the broker or against the in-memory bus of the simulator.
+type Publisher interface {
+	PublishJSON(exchange, key string, val any) error
+	PublishGob(exchange, key string, val any) error
+}
+
+type ChannelPublisher struct {
+	Ch *amqp.Channel
+}
+
+func (p ChannelPublisher) PublishJSON(exchange, key string, val any) error {
+	return PublishJSON(p.Ch, exchange, key, val)
+}
+
+func (p ChannelPublisher) PublishGob(exchange, key string, val any) error {
+	return PublishGob(p.Ch, exchange, key, val)
+}
@@ -0,0 +1,146 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const DefaultRequestTimeout = 5 * time.Second
+
+var ErrRequestTimeout = errors.New("timed out waiting for a reply")
+
+// RequestJSON publishes req to exchange with the given key and waits for a
+// single JSON reply on a private, exclusive reply queue.
+func RequestJSON[Req, Resp any](
+	conn *amqp.Connection,
+	exchange,
+	key string,
+	req Req,
+	timeout time.Duration,
+) (Resp, error) {
+	var resp Resp
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return resp, err
+	}
+	defer ch.Close()
+
+	replyQueue, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return resp, fmt.Errorf("couldn't declare reply queue: %v", err)
+	}
+
+	deliveryChan, err := ch.Consume(replyQueue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return resp, fmt.Errorf("couldn't marshal json value: %v", err)
+	}
+
+	correlationID, err := newCorrelationID()
+	if err != nil {
+		return resp, err
+	}
+
+	if err = ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{
+		ContentType:   "application/json",
+		CorrelationId: correlationID,
+		ReplyTo:       replyQueue.Name,
+		Body:          data,
+	}); err != nil {
+		return resp, fmt.Errorf("couldn't publish message: %v", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case delivery, ok := <-deliveryChan:
+			if !ok {
+				return resp, errors.New("reply channel closed")
+			}
+			if delivery.CorrelationId != correlationID {
+				continue
+			}
+			if err := json.Unmarshal(delivery.Body, &resp); err != nil {
+				return resp, fmt.Errorf("couldn't unmarshal reply: %v", err)
+			}
+			return resp, nil
+		case <-timer.C:
+			return resp, ErrRequestTimeout
+		}
+	}
+}
+
+// ServeJSON answers requests published with RequestJSON. Every request is
+// acknowledged once the handler's reply has been published.
+func ServeJSON[Req, Resp any](
+	conn *amqp.Connection,
+	exchange,
+	queueName,
+	key string,
+	handler func(Req) Resp,
+) error {
+	ch, queue, err := DeclareAndBind(conn, exchange, queueName, key, QueueTypeTransient)
+	if err != nil {
+		return err
+	}
+
+	deliveryChan, err := ch.Consume(queue.Name, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for delivery := range deliveryChan {
+			var req Req
+			if err := json.Unmarshal(delivery.Body, &req); err != nil {
+				fmt.Printf("Error unmarshaling request: %v\n", err)
+				delivery.Nack(false, false)
+				continue
+			}
+
+			data, err := json.Marshal(handler(req))
+			if err != nil {
+				fmt.Printf("Error marshaling reply: %v\n", err)
+				delivery.Nack(false, false)
+				continue
+			}
+
+			if delivery.ReplyTo != "" {
+				if err := ch.PublishWithContext(context.Background(), "", delivery.ReplyTo, false, false, amqp.Publishing{
+					ContentType:   "application/json",
+					CorrelationId: delivery.CorrelationId,
+					Body:          data,
+				}); err != nil {
+					fmt.Printf("Error publishing reply: %v\n", err)
+					delivery.Nack(false, true)
+					continue
+				}
+			}
+			delivery.Ack(false)
+		}
+	}()
+
+	return nil
+}
+
+func newCorrelationID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("couldn't generate correlation id: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
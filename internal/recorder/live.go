@@ -0,0 +1,66 @@
+package recorder
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Recorder copies messages from both exchanges into a Writer through its
+// own exclusive queue, without taking anything away from the players.
+// Everything on peril_topic is recorded; peril_direct only routes exact
+// keys, so its keys have to be bound one by one with BindDirect.
+type Recorder struct {
+	ch    *amqp.Channel
+	queue string
+	bound map[string]bool
+}
+
+func Start(conn *amqp.Connection, w *Writer) (*Recorder, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open recorder channel: %v", err)
+	}
+	queue, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't declare recorder queue: %v", err)
+	}
+	if err := ch.QueueBind(queue.Name, "#", routing.ExchangePerilTopic, false, nil); err != nil {
+		return nil, fmt.Errorf("couldn't bind recorder queue: %v", err)
+	}
+	deliveries, err := ch.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't consume recorder queue: %v", err)
+	}
+
+	go func() {
+		for d := range deliveries {
+			if err := w.Write(FromDelivery(d, time.Now())); err != nil {
+				fmt.Println(err.Error())
+			}
+		}
+	}()
+
+	return &Recorder{
+		ch:    ch,
+		queue: queue.Name,
+		bound: map[string]bool{},
+	}, nil
+}
+
+func (r *Recorder) BindDirect(key string) error {
+	if r.bound[key] {
+		return nil
+	}
+	if err := r.ch.QueueBind(r.queue, key, routing.ExchangePerilDirect, false, nil); err != nil {
+		return fmt.Errorf("couldn't bind %s: %v", key, err)
+	}
+	r.bound[key] = true
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	return r.ch.Close()
+}
@@ -0,0 +1,92 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Record is one message as it crossed an exchange. Recordings are stored
+// as one JSON Record per line.
+type Record struct {
+	Exchange    string
+	RoutingKey  string
+	Headers     map[string]any `json:",omitempty"`
+	ContentType string
+	Body        []byte
+	Timestamp   time.Time
+}
+
+// FromDelivery records a delivery. Publishers don't stamp their messages,
+// so the time of arrival is used unless the delivery carries one.
+func FromDelivery(d amqp.Delivery, now time.Time) Record {
+	if !d.Timestamp.IsZero() {
+		now = d.Timestamp
+	}
+	return Record{
+		Exchange:    d.Exchange,
+		RoutingKey:  d.RoutingKey,
+		Headers:     d.Headers,
+		ContentType: d.ContentType,
+		Body:        d.Body,
+		Timestamp:   now,
+	}
+}
+
+// Decode unmarshals the body according to its content type.
+func (r Record) Decode(val any) error {
+	if r.ContentType == "application/gob" {
+		return gob.NewDecoder(bytes.NewReader(r.Body)).Decode(val)
+	}
+	return json.Unmarshal(r.Body, val)
+}
+
+type Writer struct {
+	enc *json.Encoder
+	mu  *sync.Mutex
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		enc: json.NewEncoder(w),
+		mu:  &sync.Mutex{},
+	}
+}
+
+func (w *Writer) Write(r Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.enc.Encode(r); err != nil {
+		return fmt.Errorf("couldn't write record: %v", err)
+	}
+	return nil
+}
+
+func Read(r io.Reader) ([]Record, error) {
+	records := []Record{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: couldn't read record: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read recording: %v", err)
+	}
+	return records, nil
+}
@@ -1,9 +1,23 @@
 package routing
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
+// PlayingState is whether a game is paused. Seq goes up with every change,
+// so a client can tell an older state from the latest one when they arrive
+// out of order, e.g. a reply to a state request after a pause broadcast.
 type PlayingState struct {
 	IsPaused bool
+	Seq      int `json:",omitempty"`
+}
+
+type RoundState struct {
+	TurnBased bool
+	Round     int
+	Open      bool
+	Deadline  time.Time
 }
 
 type GameLog struct {
@@ -11,3 +25,219 @@ type GameLog struct {
 	Message     string
 	Username    string
 }
+
+type GameStatus string
+
+const (
+	GameStatusWaiting GameStatus = "waiting"
+	GameStatusRunning GameStatus = "running"
+	GameStatusEnded   GameStatus = "ended"
+)
+
+type GameSummary struct {
+	Name       string
+	Status     GameStatus
+	MaxPlayers int
+	Players    []string
+	Paused     bool
+	Map        string
+	Catalog    string
+	Combat     string
+	// Teams maps the players who joined a team to its name.
+	Teams map[string]string `json:",omitempty"`
+}
+
+// LobbyRequest names a game. Team is only read when joining, Token when
+// joining or leaving.
+type LobbyRequest struct {
+	Game     string
+	Username string
+	Token    string `json:",omitempty"`
+	Team     string `json:",omitempty"`
+}
+
+// LobbyResponse carries the game's rules, its map and unit catalog encoded
+// as JSON, in replies to joins, so clients play by the same rules as the
+// server.
+type LobbyResponse struct {
+	Game      GameSummary
+	Rules     json.RawMessage `json:",omitempty"`
+	Treasury  Treasury
+	Diplomacy DiplomacyState
+	Error     string
+}
+
+type RulesResponse struct {
+	Rules json.RawMessage
+	Error string
+}
+
+type StateResponse struct {
+	State PlayingState
+	Round RoundState
+	Error string
+}
+
+type GameList struct {
+	Games []GameSummary
+}
+
+// Treasury is a player's gold, as kept by the server. It is sent after
+// every spawn and every time the player is paid.
+type Treasury struct {
+	Game     string
+	Username string
+	Gold     int
+	Income   int
+	Round    int
+}
+
+// SpendRequest asks the server to pay for a unit the player spawns.
+type SpendRequest struct {
+	Game     string
+	Username string
+	Token    string
+	UnitID   int
+	Rank     string
+	Location string
+}
+
+type SpendResponse struct {
+	Treasury Treasury
+	Error    string
+}
+
+// PactKind is the kind of agreement two players have. Neither fights the
+// other while it holds, and allies also defend each other.
+type PactKind string
+
+const (
+	PactAlliance PactKind = "alliance"
+	PactTruce    PactKind = "truce"
+	// PactTeam binds teammates. It can't be proposed or broken.
+	PactTeam PactKind = "team"
+)
+
+type DiplomacyAction string
+
+const (
+	DiplomacyPropose DiplomacyAction = "propose"
+	DiplomacyAccept  DiplomacyAction = "accept"
+	DiplomacyBreak   DiplomacyAction = "break"
+)
+
+// DiplomacyRequest is a player proposing, accepting or breaking a pact
+// with another player. Kind is only needed to propose. Token is the one
+// the player got when they registered, so nobody negotiates in their name.
+type DiplomacyRequest struct {
+	Game     string
+	Username string
+	Token    string
+	Action   DiplomacyAction
+	With     string
+	Kind     PactKind
+}
+
+// Pact is an agreement between two players, sorted by name.
+type Pact struct {
+	Kind    PactKind
+	Players [2]string
+}
+
+type Proposal struct {
+	From string
+	To   string
+	Kind PactKind
+}
+
+// DiplomacyState is every pact and pending proposal of a game. The server
+// sends it to every player whenever it changes.
+type DiplomacyState struct {
+	Game      string
+	Pacts     []Pact
+	Proposals []Proposal
+	Teams     map[string]string `json:",omitempty"`
+}
+
+type DiplomacyResponse struct {
+	State DiplomacyState
+	Error string
+}
+
+type KickNotice struct {
+	Game   string
+	Reason string
+}
+
+// PlayerRequest registers a player, or unregisters them with the Token the
+// server gave them when they registered.
+type PlayerRequest struct {
+	Username string
+	Token    string `json:",omitempty"`
+}
+
+type PlayerResponse struct {
+	Token string `json:",omitempty"`
+	Error string
+}
+
+type Heartbeat struct {
+	Username string
+	SentAt   time.Time
+}
+
+type ChatChannel string
+
+const (
+	ChatGlobal ChatChannel = "global"
+	ChatDirect ChatChannel = "dm"
+	ChatTeam   ChatChannel = "team"
+)
+
+// ChatMessage is a message from a player to everyone in the game, to
+// another player (To) or to their team (Team). Token is the sender's
+// registration token, which the server never delivers.
+type ChatMessage struct {
+	Game     string
+	Username string
+	Token    string `json:",omitempty"`
+	Channel  ChatChannel
+	To       string `json:",omitempty"`
+	Team     string `json:",omitempty"`
+	Text     string
+	SentAt   time.Time
+}
+
+// Score is how a player stands at the end of a game.
+type Score struct {
+	Username    string
+	Team        string `json:",omitempty"`
+	Territories int
+	Units       int
+	Power       int
+	Gold        int
+}
+
+// GameOver announces the winners of a game. Winners are every player of
+// the winning side, and the scoreboard ranks everyone who played.
+type GameOver struct {
+	Game       string
+	Winners    []string
+	Team       string `json:",omitempty"`
+	Reason     string
+	Scoreboard []Score
+}
+
+// ArmyRequest asks the server for the army of a player, to resync after
+// missing one of their moves.
+type ArmyRequest struct {
+	Game     string
+	Username string
+	Of       string
+}
+
+// ArmyResponse carries the army as a JSON snapshot move.
+type ArmyResponse struct {
+	Army  json.RawMessage `json:",omitempty"`
+	Error string
+}
@@ -1,16 +1,79 @@
 package routing
 
+import "strings"
+
 const (
 	ArmyMovesPrefix = "army_moves"
 
+	MoveIntentsPrefix = "move_intents"
+
+	SightingsKey = "sightings"
+
+	RejectionsKey = "rejections"
+
 	WarRecognitionsPrefix = "war"
 
+	WarDeclarationsPrefix = "war_declarations"
+
 	PauseKey = "pause"
 
+	RoundKey = "round"
+
+	OrdersPrefix = "orders"
+
 	GameLogSlug = "game_logs"
+
+	KickKey = "kick"
+
+	TreasuryKey = "treasury"
+
+	DiplomacyKey = "diplomacy"
+
+	GameOverKey = "game_over"
+
+	ChatPrefix = "chat"
+
+	PresencePrefix = "presence"
+)
+
+const (
+	LobbyListKey  = "lobby.list"
+	LobbyJoinKey  = "lobby.join"
+	LobbyLeaveKey = "lobby.leave"
+	LobbyStateKey = "lobby.state"
+	LobbyRulesKey = "lobby.rules"
+	// LobbyGamesKey is where the server announces, on peril_topic, every
+	// game created and every player who joins one.
+	LobbyGamesKey = "lobby.games"
+
+	PlayerRegisterKey   = "players.register"
+	PlayerUnregisterKey = "players.unregister"
+
+	TreasurySpendKey = "treasury.spend"
+
+	DiplomacyRequestKey = "diplomacy.request"
+
+	ArmyRequestKey = "army.request"
+
+	// ChatSendKey is where players send chat messages, on peril_direct,
+	// for the server to check and deliver.
+	ChatSendKey = "chat.send"
 )
 
 const (
 	ExchangePerilDirect = "peril_direct"
 	ExchangePerilTopic  = "peril_topic"
 )
+
+// ChatKey is where the server delivers the chat messages of a game to a
+// player, on peril_direct, whatever channel they were sent on. Players
+// send theirs to ChatSendKey.
+func ChatKey(game, username string) string {
+	return GameKey(game, ChatPrefix, username)
+}
+
+// GameKey namespaces a routing key (or queue name) under a game session,
+// e.g. GameKey("alpha", ArmyMovesPrefix, "bob") is "alpha.army_moves.bob".
+func GameKey(game string, parts ...string) string {
+	return strings.Join(append([]string{game}, parts...), ".")
+}
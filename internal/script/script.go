@@ -0,0 +1,106 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+const DefaultExpectTimeout = 10 * time.Second
+
+type Kind int
+
+const (
+	KindCommand Kind = iota
+	KindWait
+	KindExpect
+)
+
+// Statement is one step of a script. Commands are passed to the client as
+// words, exactly as if they had been typed at the prompt.
+type Statement struct {
+	Line     int
+	Kind     Kind
+	Words    []string
+	Duration time.Duration
+	Event    string
+}
+
+func (s Statement) String() string {
+	return strings.Join(s.Words, " ")
+}
+
+// Parse reads a script. Statements are separated by newlines or semicolons
+// and everything after a '#' is a comment:
+//
+//	spawn europe infantry   # first unit
+//	wait 2s; move asia 1
+//	expect move_detected 30s
+func Parse(r io.Reader) ([]Statement, error) {
+	statements := []Statement{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		for _, part := range strings.Split(text, ";") {
+			words := strings.Fields(part)
+			if len(words) == 0 {
+				continue
+			}
+			s, err := parseStatement(line, words)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, s)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read script: %v", err)
+	}
+	return statements, nil
+}
+
+func ParseString(src string) ([]Statement, error) {
+	return Parse(strings.NewReader(src))
+}
+
+func parseStatement(line int, words []string) (Statement, error) {
+	s := Statement{Line: line, Kind: KindCommand, Words: words}
+	switch words[0] {
+	case "wait":
+		if len(words) != 2 {
+			return Statement{}, fmt.Errorf("line %d: usage: wait <duration>", line)
+		}
+		d, err := time.ParseDuration(words[1])
+		if err != nil || d < 0 {
+			return Statement{}, fmt.Errorf("line %d: %q is not a valid duration", line, words[1])
+		}
+		s.Kind = KindWait
+		s.Duration = d
+
+	case "expect":
+		if len(words) < 2 || len(words) > 3 {
+			return Statement{}, fmt.Errorf("line %d: usage: expect <event> [timeout]", line)
+		}
+		if !slices.Contains(gamelogic.EventNames(), words[1]) {
+			return Statement{}, fmt.Errorf("line %d: unknown event %q, expected one of %s", line, words[1], strings.Join(gamelogic.EventNames(), ", "))
+		}
+		s.Kind = KindExpect
+		s.Event = words[1]
+		s.Duration = DefaultExpectTimeout
+		if len(words) == 3 {
+			d, err := time.ParseDuration(words[2])
+			if err != nil || d <= 0 {
+				return Statement{}, fmt.Errorf("line %d: %q is not a valid timeout", line, words[2])
+			}
+			s.Duration = d
+		}
+	}
+	return s, nil
+}
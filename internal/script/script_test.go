@@ -0,0 +1,74 @@
+package script
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseString(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []Statement
+		wantErr bool
+	}{
+		{
+			name: "commands, waits and expectations",
+			src:  "spawn europe infantry   # first unit\nwait 2s; move asia 1\n\nexpect move_detected 30s\nexpect units_moved",
+			want: []Statement{
+				{Line: 1, Kind: KindCommand, Words: []string{"spawn", "europe", "infantry"}},
+				{Line: 2, Kind: KindWait, Words: []string{"wait", "2s"}, Duration: 2 * time.Second},
+				{Line: 2, Kind: KindCommand, Words: []string{"move", "asia", "1"}},
+				{Line: 4, Kind: KindExpect, Words: []string{"expect", "move_detected", "30s"}, Duration: 30 * time.Second, Event: "move_detected"},
+				{Line: 5, Kind: KindExpect, Words: []string{"expect", "units_moved"}, Duration: DefaultExpectTimeout, Event: "units_moved"},
+			},
+		},
+		{
+			name: "comments and empty statements",
+			src:  "# nothing to do\n;;  ; # still nothing",
+			want: []Statement{},
+		},
+		{
+			name:    "wait without a duration",
+			src:     "wait",
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			src:     "wait soon",
+			wantErr: true,
+		},
+		{
+			name:    "negative duration",
+			src:     "wait -1s",
+			wantErr: true,
+		},
+		{
+			name:    "unknown event",
+			src:     "expect dragons",
+			wantErr: true,
+		},
+		{
+			name:    "zero timeout",
+			src:     "expect move_detected 0s",
+			wantErr: true,
+		},
+		{
+			name:    "too many words",
+			src:     "expect move_detected 1s 2s",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseString(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseString() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseString() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// MaxDeliveries is how often a requeued message is redelivered before the
+// bus dead-letters it, like a broker with a delivery limit would.
+const MaxDeliveries = 100
+
+type Message struct {
+	Seq         int
+	Time        time.Time
+	Exchange    string
+	RoutingKey  string
+	ContentType string
+	Body        []byte
+}
+
+type queue struct {
+	name      string
+	consumers []func(Message) pubsub.AckType
+	next      int
+}
+
+type binding struct {
+	exchange string
+	key      string
+	queue    *queue
+}
+
+type delivery struct {
+	queue    *queue
+	msg      Message
+	attempts int
+}
+
+// Bus is an in-memory broker. Messages are delivered one at a time in the
+// order they were published and consumers of a queue take turns, so a run
+// only depends on what is published.
+type Bus struct {
+	clock    *Clock
+	queues   map[string]*queue
+	bindings []binding
+	pending  []delivery
+	current  Message
+	log      []Message
+	dead     []Message
+}
+
+func NewBus(clock *Clock) *Bus {
+	return &Bus{
+		clock:  clock,
+		queues: map[string]*queue{},
+	}
+}
+
+func (b *Bus) PublishJSON(exchange, key string, val any) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal json value: %v", err)
+	}
+	b.publish(exchange, key, "application/json", data)
+	return nil
+}
+
+func (b *Bus) PublishGob(exchange, key string, val any) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(val); err != nil {
+		return fmt.Errorf("couldn't encode gob: %v", err)
+	}
+	b.publish(exchange, key, "application/gob", buf.Bytes())
+	return nil
+}
+
+func (b *Bus) publish(exchange, key, contentType string, body []byte) {
+	msg := Message{
+		Seq:         len(b.log) + 1,
+		Time:        b.clock.Now(),
+		Exchange:    exchange,
+		RoutingKey:  key,
+		ContentType: contentType,
+		Body:        body,
+	}
+	b.log = append(b.log, msg)
+
+	seen := map[*queue]bool{}
+	for _, bd := range b.bindings {
+		if bd.exchange != exchange || seen[bd.queue] || !matches(exchange, bd.key, key) {
+			continue
+		}
+		seen[bd.queue] = true
+		b.pending = append(b.pending, delivery{queue: bd.queue, msg: msg})
+	}
+}
+
+// Subscribe declares the queue if needed, binds it and adds a consumer.
+// Several subscriptions to the same queue share its messages.
+func Subscribe[T any](b *Bus, exchange, queueName, key string, handler func(T) pubsub.AckType) {
+	SubscribeKeyed(b, exchange, queueName, key, func(_ string, val T) pubsub.AckType {
+		return handler(val)
+	})
+}
+
+// SubscribeKeyed is Subscribe for handlers that need the routing key, like
+// pubsub.SubscribeJSONKeyed.
+func SubscribeKeyed[T any](b *Bus, exchange, queueName, key string, handler func(string, T) pubsub.AckType) {
+	q, ok := b.queues[queueName]
+	if !ok {
+		q = &queue{name: queueName}
+		b.queues[queueName] = q
+	}
+	b.bindings = append(b.bindings, binding{exchange: exchange, key: key, queue: q})
+	q.consumers = append(q.consumers, func(msg Message) pubsub.AckType {
+		var val T
+		if err := decode(msg, &val); err != nil {
+			return pubsub.AckTypeNackDiscard
+		}
+		return handler(msg.RoutingKey, val)
+	})
+}
+
+// Drain delivers messages until every queue is empty, including the
+// messages published by the consumers themselves.
+func (b *Bus) Drain() {
+	for len(b.pending) > 0 {
+		d := b.pending[0]
+		b.pending = b.pending[1:]
+		if len(d.queue.consumers) == 0 {
+			continue
+		}
+		consume := d.queue.consumers[d.queue.next%len(d.queue.consumers)]
+		d.queue.next++
+		d.attempts++
+
+		b.current = d.msg
+		ack := consume(d.msg)
+		b.current = Message{}
+
+		switch ack {
+		case pubsub.AckTypeAck:
+		case pubsub.AckTypeNackRequeue:
+			if d.attempts < MaxDeliveries {
+				b.pending = append(b.pending, d)
+				continue
+			}
+			b.dead = append(b.dead, d.msg)
+		default:
+			b.dead = append(b.dead, d.msg)
+		}
+	}
+}
+
+// Current returns the message being delivered, or the zero Message outside
+// of a consumer.
+func (b *Bus) Current() Message {
+	return b.current
+}
+
+func (b *Bus) Messages() []Message {
+	return append([]Message{}, b.log...)
+}
+
+func (b *Bus) DeadLetters() []Message {
+	return append([]Message{}, b.dead...)
+}
+
+func decode(msg Message, val any) error {
+	if msg.ContentType == "application/gob" {
+		return gob.NewDecoder(bytes.NewReader(msg.Body)).Decode(val)
+	}
+	return json.Unmarshal(msg.Body, val)
+}
+
+// matches routes key against a binding key, with the topic exchange's '*'
+// (one word) and '#' (any number of words) wildcards.
+func matches(exchange, pattern, key string) bool {
+	if exchange != routing.ExchangePerilTopic {
+		return pattern == key
+	}
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	}
+	return len(key) > 0 && pattern[0] == key[0] && matchWords(pattern[1:], key[1:])
+}
@@ -0,0 +1,23 @@
+package sim
+
+import "time"
+
+// Epoch is where the virtual clock of every simulation starts.
+var Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is a virtual clock that only moves when the simulation advances it.
+type Clock struct {
+	now time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	return c.now
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,207 @@
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/bot"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// checker collects what the players saw during a run and reports every
+// invariant that did not hold:
+//   - no player has a negative budget or a malformed unit,
+//   - every player knows how much gold the server says they have, and it
+//     is never negative,
+//   - no unit ID is ever handed out twice to the same player,
+//   - units never move further than their movement allows,
+//   - the server never refuses a move a player made,
+//   - every player's army is the one the server knows,
+//   - teammates never fight each other,
+//   - every player hears of the end of the game, with the same winners.
+type checker struct {
+	bus     *Bus
+	games   *lobby.Lobby
+	game    string
+	rules   gamelogic.Rules
+	spawned map[string]map[int]bool
+	seen    map[string]map[int]gamelogic.Location
+	// fought are the wars players fought battles for. In fog of war every
+	// player who sees a war is sent the same message.
+	fought   map[string]bool
+	endings  map[string]routing.GameOver
+	failures []error
+}
+
+func newChecker(bus *Bus, games *lobby.Lobby, game string, rules gamelogic.Rules) *checker {
+	return &checker{
+		bus:     bus,
+		games:   games,
+		game:    game,
+		rules:   rules,
+		spawned: map[string]map[int]bool{},
+		seen:    map[string]map[int]gamelogic.Location{},
+		fought:  map[string]bool{},
+		endings: map[string]routing.GameOver{},
+	}
+}
+
+func (c *checker) watch(b *bot.Bot) {
+	c.spawned[b.Username] = map[int]bool{}
+	b.GS.Subscribe(gamelogic.SubscriberFunc(func(e gamelogic.Event) {
+		switch e := e.(type) {
+		case gamelogic.UnitSpawned:
+			if c.spawned[b.Username][e.Unit.ID] {
+				c.fail(fmt.Errorf("%s was handed unit ID %d twice", b.Username, e.Unit.ID))
+			}
+			c.spawned[b.Username][e.Unit.ID] = true
+		case gamelogic.MoveRejected:
+			c.fail(fmt.Errorf("the server refused a move of %s to %s: %s", b.Username, e.Move.ToLocation, e.Reason))
+		case gamelogic.GameEnded:
+			c.endings[b.Username] = e.Over
+		case gamelogic.WarResolved:
+			if len(e.Results) > 0 {
+				battles := []gamelogic.Battle{}
+				for _, result := range e.Results {
+					battles = append(battles, result.Battle)
+				}
+				c.battles(battles)
+			}
+		}
+	}))
+}
+
+// battles records the battles of the war message being delivered.
+func (c *checker) battles(battles []gamelogic.Battle) {
+	game, _ := c.games.Get(c.game)
+	for _, b := range battles {
+		if team := game.Teams[b.Attacker]; team != "" && game.Teams[b.Defender] == team {
+			c.fail(fmt.Errorf("%s fought their teammate %s in %s", b.Attacker, b.Defender, b.Location))
+		}
+	}
+	c.fought[string(c.bus.Current().Body)] = true
+}
+
+func (c *checker) players(step int, bots []*bot.Bot) {
+	armies, err := c.games.Armies(c.game)
+	if err != nil {
+		c.fail(fmt.Errorf("step %d: %v", step, err))
+	}
+	for _, b := range bots {
+		c.army(step, b, armies[b.Username])
+		if b.Budget() < 0 {
+			c.fail(fmt.Errorf("step %d: %s has a negative budget of %d", step, b.Username, b.Budget()))
+		}
+		c.gold(step, b)
+		for id, unit := range b.GS.GetPlayerSnap().Units {
+			switch {
+			case id != unit.ID || id <= 0:
+				c.fail(fmt.Errorf("step %d: %s has unit %d stored under ID %d", step, b.Username, unit.ID, id))
+			case !c.known(unit.Rank):
+				c.fail(fmt.Errorf("step %d: %s has unit %d of unknown rank %q", step, b.Username, id, unit.Rank))
+			case !c.rules.Map.Has(unit.Location):
+				c.fail(fmt.Errorf("step %d: %s has unit %d in unknown location %q", step, b.Username, id, unit.Location))
+			}
+		}
+		c.moves(step, b)
+	}
+}
+
+// army compares the units b thinks it has with the ones the server knows,
+// which only tell apart when a player misses a war or a move it made.
+func (c *checker) army(step int, b *bot.Bot, want gamelogic.Player) {
+	got := b.GS.GetPlayerSnap().Units
+	for id, unit := range want.Units {
+		if mine, ok := got[id]; !ok {
+			c.fail(fmt.Errorf("step %d: %s lost track of %s %d in %s", step, b.Username, unit.Rank, id, unit.Location))
+		} else if mine != unit {
+			c.fail(fmt.Errorf("step %d: %s thinks %s %d is in %s, the server says %s", step, b.Username, unit.Rank, id, mine.Location, unit.Location))
+		}
+	}
+	for id, unit := range got {
+		if _, ok := want.Units[id]; !ok {
+			c.fail(fmt.Errorf("step %d: %s still has %s %d in %s, the server says it is gone", step, b.Username, unit.Rank, id, unit.Location))
+		}
+	}
+}
+
+func (c *checker) gold(step int, b *bot.Bot) {
+	want, err := c.games.Treasury(c.game, b.Username)
+	if err != nil {
+		c.fail(fmt.Errorf("step %d: %s has no treasury: %v", step, b.Username, err))
+		return
+	}
+	got, _ := b.GS.Treasury()
+	switch {
+	case want.Gold < 0:
+		c.fail(fmt.Errorf("step %d: %s has %d gold", step, b.Username, want.Gold))
+	case got.Gold != want.Gold:
+		c.fail(fmt.Errorf("step %d: %s thinks they have %d gold, the server says %d", step, b.Username, got.Gold, want.Gold))
+	}
+}
+
+func (c *checker) known(rank gamelogic.UnitRank) bool {
+	_, ok := c.rules.Catalog.Lookup(rank)
+	return ok
+}
+
+// moves compares where the units of b are with where they were at the
+// previous step. A unit moves at most once per step.
+func (c *checker) moves(step int, b *bot.Bot) {
+	units := b.GS.GetPlayerSnap().Units
+	for id, from := range c.seen[b.Username] {
+		unit, ok := units[id]
+		if !ok || unit.Location == from {
+			continue
+		}
+		t, _ := c.rules.Catalog.Lookup(unit.Rank)
+		if hops := len(c.rules.Map.Route(from, unit.Location)); hops > t.Movement {
+			c.fail(fmt.Errorf("step %d: %s moved %s %d from %s to %s, %d territories away", step, b.Username, unit.Rank, id, from, unit.Location, hops))
+		}
+	}
+	c.seen[b.Username] = map[int]gamelogic.Location{}
+	for id, unit := range units {
+		c.seen[b.Username][id] = unit.Location
+	}
+}
+
+// over reports the end of the game once every player has heard of it.
+func (c *checker) over() (routing.GameOver, bool) {
+	if len(c.endings) == 0 {
+		return routing.GameOver{}, false
+	}
+	var first routing.GameOver
+	usernames := []string{}
+	for username := range c.spawned {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	for _, username := range usernames {
+		over, ok := c.endings[username]
+		if !ok {
+			c.fail(fmt.Errorf("%s never heard that the game is over", username))
+			continue
+		}
+		if first.Game == "" {
+			first = over
+		} else if !slices.Equal(over.Winners, first.Winners) {
+			c.fail(fmt.Errorf("%s thinks %v won, others %v", username, over.Winners, first.Winners))
+		}
+	}
+	return first, true
+}
+
+func (c *checker) wars() int {
+	return len(c.fought)
+}
+
+func (c *checker) violations() []error {
+	return append([]error{}, c.failures...)
+}
+
+func (c *checker) fail(err error) {
+	c.failures = append(c.failures, err)
+}
@@ -0,0 +1,255 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/bot"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Config describes a simulated game. Zero fields get the defaults below.
+type Config struct {
+	Seed       int64
+	Game       string
+	Bots       int
+	Strategies []string
+	// Teams are assigned to the bots in turn, none when empty.
+	Teams         []string
+	Budget        int
+	Steps         int
+	Tick          time.Duration
+	TurnBased     bool
+	RoundDuration time.Duration
+	Rules         gamelogic.Rules
+	// Restart is the step every bot restarts at, resuming its game state
+	// from its journal like a client started with -state. Bots never
+	// restart when it is zero.
+	Restart int
+}
+
+func (c Config) withDefaults() Config {
+	if c.Game == "" {
+		c.Game = "sim"
+	}
+	if c.Bots == 0 {
+		c.Bots = 2
+	}
+	if len(c.Strategies) == 0 {
+		c.Strategies = bot.Strategies()
+	}
+	if c.Budget == 0 {
+		c.Budget = bot.DefaultBudget
+	}
+	if c.Steps == 0 {
+		c.Steps = 100
+	}
+	if c.Tick == 0 {
+		c.Tick = time.Second
+	}
+	if c.RoundDuration == 0 {
+		c.RoundDuration = 10 * time.Second
+	}
+	if c.Rules.Map == nil {
+		c.Rules.Map = gamelogic.ClassicMap()
+	}
+	if c.Rules.Catalog == nil {
+		c.Rules.Catalog = gamelogic.ClassicCatalog()
+	}
+	if c.Rules.Economy == (gamelogic.Economy{}) {
+		c.Rules.Economy = gamelogic.ClassicEconomy()
+	}
+	return c
+}
+
+type Result struct {
+	Messages    []Message
+	DeadLetters []Message
+	Players     []gamelogic.Player
+	Treasuries  []routing.Treasury
+	Wars        int
+	// Steps is how many steps were played, fewer than asked when the game
+	// was won.
+	Steps    int
+	GameOver *routing.GameOver
+	// BotErrors are commands the bots tried that the game refused. They
+	// are part of normal play and don't fail the run.
+	BotErrors []string
+}
+
+// Run plays a whole game between bots in one process: the lobby and round
+// logic of the server, and every bot, talk over an in-memory bus with a
+// virtual clock. The same Config always gives the same Result. The error
+// lists every broken invariant, so from a test:
+//
+//	if _, err := sim.Run(sim.Config{Seed: 42, Bots: 4}); err != nil {
+//		t.Fatal(err)
+//	}
+func Run(cfg Config) (*Result, error) {
+	cfg = cfg.withDefaults()
+	for _, name := range cfg.Strategies {
+		if _, err := bot.NewStrategy(name); err != nil {
+			return nil, err
+		}
+	}
+
+	clock := NewClock(Epoch)
+	bus := NewBus(clock)
+	games := lobby.NewSeeded(cfg.Seed)
+	turns := lobby.NewTurns()
+	players := lobby.NewRegistry()
+	check := newChecker(bus, games, cfg.Game, cfg.Rules)
+	result := &Result{}
+
+	if _, err := games.Create(cfg.Game, cfg.Bots, cfg.Rules); err != nil {
+		return nil, err
+	}
+	serve(bus)
+
+	bots := []*bot.Bot{}
+	for i := range cfg.Bots {
+		strategy, _ := bot.NewStrategy(cfg.Strategies[i%len(cfg.Strategies)])
+		b := bot.New(fmt.Sprintf("bot-%d", i+1), strategy, cfg.Budget, cfg.Seed+int64(i))
+		token, err := players.Register(b.Username, clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		b.Token = token
+		b.Game = cfg.Game
+		b.Publisher = bus
+		b.Now = clock.Now
+		b.SetRules(cfg.Rules)
+		team := ""
+		if len(cfg.Teams) > 0 {
+			team = cfg.Teams[i%len(cfg.Teams)]
+		}
+		if _, err := games.Join(cfg.Game, b.Username, team); err != nil {
+			return nil, err
+		}
+		admit(bus, games, turns, players, cfg.Game, b.Username)
+		treasury, err := games.Treasury(cfg.Game, b.Username)
+		if err != nil {
+			return nil, err
+		}
+		b.World.SetSource(lobby.LocalArmies{Games: games, Game: cfg.Game})
+		b.GS.SetBank(lobby.LocalBank{Games: games, Game: cfg.Game, Username: b.Username})
+		b.GS.SetTreasury(treasury)
+		connect(bus, b)
+		check.watch(b)
+		bots = append(bots, b)
+	}
+
+	diplomacy, err := games.Diplomacy(cfg.Game)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bots {
+		b.GS.SetDiplomacy(diplomacy)
+	}
+	if _, err := games.Start(cfg.Game); err != nil {
+		return nil, err
+	}
+	if err := lobby.PublishPause(bus, games, cfg.Game); err != nil {
+		return nil, err
+	}
+	if cfg.TurnBased {
+		if _, err := turns.Enable(cfg.Game, cfg.RoundDuration); err != nil {
+			return nil, err
+		}
+	}
+	bus.Drain()
+
+	payday := clock.Now().Add(lobby.IncomeInterval)
+	for step := range cfg.Steps {
+		if cfg.Restart > 0 && step == cfg.Restart {
+			for _, b := range bots {
+				fresh := gamelogic.Snapshot{Player: gamelogic.Player{Username: b.Username, Units: map[int]gamelogic.Unit{}}}
+				b.GS.Resume(fresh, b.GS.Journal())
+			}
+		}
+		for _, b := range bots {
+			if err := b.Play(); err != nil {
+				result.BotErrors = append(result.BotErrors, fmt.Sprintf("step %d: %s: %v", step, b.Username, err))
+			}
+			bus.Drain()
+		}
+		if cfg.TurnBased {
+			if _, err := turns.Advance(bus, games, cfg.Game, clock.Now()); err != nil {
+				return nil, err
+			}
+			bus.Drain()
+		} else if !clock.Now().Before(payday) {
+			if err := lobby.PayDue(bus, games, turns, clock.Now()); err != nil {
+				return nil, err
+			}
+			payday = payday.Add(lobby.IncomeInterval)
+			bus.Drain()
+		}
+		check.players(step, bots)
+		clock.Advance(cfg.Tick)
+		result.Steps++
+		if over, ok := check.over(); ok {
+			result.GameOver = &over
+			break
+		}
+	}
+
+	result.Messages = bus.Messages()
+	result.DeadLetters = bus.DeadLetters()
+	result.Wars = check.wars()
+	for _, b := range bots {
+		result.Players = append(result.Players, b.GS.GetPlayerSnap())
+		treasury, _ := b.GS.Treasury()
+		result.Treasuries = append(result.Treasuries, treasury)
+	}
+	return result, errors.Join(check.violations()...)
+}
+
+// quiet drops what the server handlers report.
+func quiet(string, ...any) {}
+
+// serve binds the queues the server consumes for every game. Game logs are
+// only kept in the message log.
+func serve(bus *Bus) {
+	Subscribe(bus, routing.ExchangePerilTopic, routing.GameLogSlug, routing.GameKey("*", routing.GameLogSlug, "*"),
+		func(routing.GameLog) pubsub.AckType {
+			return pubsub.AckTypeAck
+		})
+}
+
+// admit binds the keys a bot sends its moves, orders and wars to, which
+// only the server consumes, to the server's own handlers, like the server
+// does when a player joins.
+func admit(bus *Bus, games *lobby.Lobby, turns *lobby.Turns, players *lobby.Registry, game, username string) {
+	key := routing.GameKey(game, routing.MoveIntentsPrefix, username)
+	SubscribeKeyed(bus, routing.ExchangePerilDirect, key, key, lobby.HandlerIntent(bus, games, turns, players, game, bus.clock.Now, quiet))
+	key = routing.GameKey(game, routing.OrdersPrefix, username)
+	Subscribe(bus, routing.ExchangePerilDirect, key, key, lobby.HandlerOrders(games, turns, players, quiet))
+	key = routing.GameKey(game, routing.WarDeclarationsPrefix, username)
+	SubscribeKeyed(bus, routing.ExchangePerilDirect, key, key, lobby.HandlerWar(bus, games, players, game, quiet))
+}
+
+// connect binds a bot's queues the same way cmd/bot does.
+func connect(bus *Bus, b *bot.Bot) {
+	game := b.Game
+	Subscribe(bus, routing.ExchangePerilDirect, routing.GameKey(game, routing.PauseKey, b.Username), routing.GameKey(game, routing.PauseKey), b.HandlerPause())
+	Subscribe(bus, routing.ExchangePerilDirect, routing.GameKey(game, routing.RoundKey, b.Username), routing.GameKey(game, routing.RoundKey), b.HandlerRound())
+	Subscribe(bus, routing.ExchangePerilDirect, routing.GameKey(game, routing.TreasuryKey, b.Username), routing.GameKey(game, routing.TreasuryKey, b.Username), b.HandlerTreasury())
+	Subscribe(bus, routing.ExchangePerilDirect, routing.GameKey(game, routing.DiplomacyKey, b.Username), routing.GameKey(game, routing.DiplomacyKey), b.HandlerDiplomacy())
+	Subscribe(bus, routing.ExchangePerilDirect, routing.GameKey(game, routing.GameOverKey, b.Username), routing.GameKey(game, routing.GameOverKey), b.HandlerGameOver())
+	if b.GS.Rules().Fog {
+		Subscribe(bus, routing.ExchangePerilDirect, routing.GameKey(game, routing.SightingsKey, b.Username), routing.GameKey(game, routing.SightingsKey, b.Username), b.HandlerSighting())
+	} else {
+		Subscribe(bus, routing.ExchangePerilTopic, routing.GameKey(game, routing.ArmyMovesPrefix, b.Username), routing.GameKey(game, routing.ArmyMovesPrefix, "*"), b.HandlerMove())
+	}
+	Subscribe(bus, routing.ExchangePerilDirect, routing.GameKey(game, routing.RejectionsKey, b.Username), routing.GameKey(game, routing.RejectionsKey, b.Username), b.HandlerRejection())
+	warKey := routing.GameKey(game, routing.WarRecognitionsPrefix)
+	if b.GS.Rules().Fog {
+		warKey = routing.GameKey(game, routing.WarRecognitionsPrefix, b.Username)
+	}
+	Subscribe(bus, routing.ExchangePerilDirect, routing.GameKey(game, routing.WarRecognitionsPrefix, b.Username), warKey, b.HandlerWar())
+}
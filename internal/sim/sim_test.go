@@ -0,0 +1,54 @@
+package sim
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+func TestRunKeepsInvariants(t *testing.T) {
+	fog := gamelogic.ClassicRules()
+	fog.Fog = true
+	dice := gamelogic.ClassicRules()
+	dice.Combat = gamelogic.CombatDice
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"classic", Config{Seed: 42, Bots: 4}},
+		{"turns", Config{Seed: 42, Bots: 3, TurnBased: true}},
+		{"fog", Config{Seed: 42, Bots: 3, Rules: fog}},
+		{"dice", Config{Seed: 42, Bots: 3, Rules: dice}},
+		{"teams", Config{Seed: 42, Bots: 4, Teams: []string{"red", "blue"}}},
+		{"restart", Config{Seed: 42, Bots: 3, Restart: 40}},
+		{"restart in fog", Config{Seed: 42, Bots: 3, Restart: 40, Rules: fog}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Run(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Steps == 0 || len(result.Messages) == 0 {
+				t.Fatalf("nothing was played: %d step(s), %d message(s)", result.Steps, len(result.Messages))
+			}
+		})
+	}
+}
+
+func TestRunSameSeed(t *testing.T) {
+	cfg := Config{Seed: 7, Bots: 3, Steps: 50}
+	first, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first.Players, second.Players) || first.Wars != second.Wars {
+		t.Errorf("the same seed played two different games")
+	}
+}
@@ -0,0 +1,198 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// SnapshotEvery is how many journal entries are appended between two
+// snapshots, to keep loading fast however long the journal grows.
+const SnapshotEvery = 50
+
+// Store keeps the journal of every game and player in a directory, as
+// <dir>/<game>/<username>.journal, with a snapshot of the state in
+// <dir>/<game>/<username>.json. The journal is append-only and keeps the
+// whole history, the snapshot only saves replaying all of it.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultDir is where clients keep their snapshots unless told otherwise.
+func DefaultDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "peril", "state")
+}
+
+func (s *Store) Path(game, username string) (string, error) {
+	return s.path(game, username, ".json")
+}
+
+func (s *Store) JournalPath(game, username string) (string, error) {
+	return s.path(game, username, ".journal")
+}
+
+func (s *Store) path(game, username, ext string) (string, error) {
+	for _, name := range []string{game, username} {
+		if name == "" || strings.ContainsAny(name, `./\`) {
+			return "", fmt.Errorf("%q can't be used in a snapshot path", name)
+		}
+	}
+	return filepath.Join(s.dir, game, username+ext), nil
+}
+
+// Save writes the snapshot to a temporary file first, so a crash while
+// saving never leaves a truncated snapshot behind.
+func (s *Store) Save(game string, snap gamelogic.Snapshot) error {
+	path, err := s.Path(game, snap.Player.Username)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal snapshot: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("couldn't create snapshot directory: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("couldn't write snapshot: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("couldn't write snapshot: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) Append(game, username string, e gamelogic.JournalEntry) error {
+	path, err := s.JournalPath(game, username)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal journal entry: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("couldn't create snapshot directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("couldn't open journal: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("couldn't write journal: %v", err)
+	}
+	return nil
+}
+
+// Load returns the latest snapshot of a player and their whole journal. It
+// reports false when nothing was saved for the game yet.
+func (s *Store) Load(game, username string) (gamelogic.Snapshot, []gamelogic.JournalEntry, bool, error) {
+	snap, hasSnapshot, err := s.loadSnapshot(game, username)
+	if err != nil {
+		return gamelogic.Snapshot{}, nil, false, err
+	}
+	entries, err := s.loadJournal(game, username)
+	if err != nil {
+		return gamelogic.Snapshot{}, nil, false, err
+	}
+	if !hasSnapshot {
+		snap = gamelogic.Snapshot{Player: gamelogic.Player{Username: username, Units: map[int]gamelogic.Unit{}}}
+	}
+	return snap, entries, hasSnapshot || len(entries) > 0, nil
+}
+
+func (s *Store) loadSnapshot(game, username string) (gamelogic.Snapshot, bool, error) {
+	path, err := s.Path(game, username)
+	if err != nil {
+		return gamelogic.Snapshot{}, false, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return gamelogic.Snapshot{}, false, nil
+	}
+	if err != nil {
+		return gamelogic.Snapshot{}, false, fmt.Errorf("couldn't read snapshot: %v", err)
+	}
+	var snap gamelogic.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return gamelogic.Snapshot{}, false, fmt.Errorf("couldn't read snapshot %s: %v", path, err)
+	}
+	if snap.Player.Username != username {
+		return gamelogic.Snapshot{}, false, fmt.Errorf("snapshot %s belongs to %s", path, snap.Player.Username)
+	}
+	return snap, true, nil
+}
+
+// loadJournal cuts a torn last line, which is what a crash in the middle of
+// an append leaves behind, so the next append starts on a line of its own.
+// Any other line that can't be read is reported.
+func (s *Store) loadJournal(game, username string) ([]gamelogic.JournalEntry, error) {
+	path, err := s.JournalPath(game, username)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read journal: %v", err)
+	}
+
+	entries := []gamelogic.JournalEntry{}
+	read := 0
+	for line := 1; read < len(data); line++ {
+		end := bytes.IndexByte(data[read:], '\n')
+		var e gamelogic.JournalEntry
+		if end < 0 || json.Unmarshal(data[read:read+end], &e) != nil {
+			if end >= 0 && read+end+1 < len(data) {
+				return nil, fmt.Errorf("couldn't read journal %s: line %d is corrupt", path, line)
+			}
+			if err := os.Truncate(path, int64(read)); err != nil {
+				return nil, fmt.Errorf("couldn't cut the torn end of journal %s: %v", path, err)
+			}
+			break
+		}
+		entries = append(entries, e)
+		read += end + 1
+	}
+	return entries, nil
+}
+
+// Autosave appends every journal entry of gs to the store, and snapshots it
+// every SnapshotEvery entries.
+func (s *Store) Autosave(gs *gamelogic.GameState, game string, onError func(error)) {
+	gs.Subscribe(gamelogic.SubscriberFunc(func(e gamelogic.Event) {
+		j, ok := e.(gamelogic.Journaled)
+		if !ok {
+			return
+		}
+		if err := s.Append(game, gs.GetUsername(), j.Entry); err != nil {
+			onError(err)
+			return
+		}
+		if j.Entry.Seq%SnapshotEvery != 0 {
+			return
+		}
+		if err := s.Save(game, gs.Snapshot()); err != nil {
+			onError(err)
+		}
+	}))
+}
@@ -0,0 +1,204 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+func TestSaveLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved []gamelogic.Snapshot
+		want  gamelogic.Snapshot
+		found bool
+	}{
+		{
+			name:  "nothing saved",
+			want:  gamelogic.Snapshot{Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{}}},
+			found: false,
+		},
+		{
+			name: "one snapshot",
+			saved: []gamelogic.Snapshot{
+				{
+					Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{
+						1: {ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"},
+						3: {ID: 3, Rank: gamelogic.RankCavalry, Location: "asia"},
+					}},
+					Paused:     true,
+					LastUnitID: 3,
+					Seq:        4,
+				},
+			},
+			want: gamelogic.Snapshot{
+				Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{
+					1: {ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"},
+					3: {ID: 3, Rank: gamelogic.RankCavalry, Location: "asia"},
+				}},
+				Paused:     true,
+				LastUnitID: 3,
+				Seq:        4,
+			},
+			found: true,
+		},
+		{
+			name: "latest snapshot",
+			saved: []gamelogic.Snapshot{
+				{Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{1: {ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"}}}, LastUnitID: 1, Seq: 1},
+				{Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{}}, LastUnitID: 1, Seq: 2},
+			},
+			want:  gamelogic.Snapshot{Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{}}, LastUnitID: 1, Seq: 2},
+			found: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore(t.TempDir())
+			for _, snap := range tt.saved {
+				if err := store.Save("g", snap); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, entries, found, err := store.Load("g", "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != tt.found || len(entries) != 0 {
+				t.Errorf("Load() found %v with %d entries, want %v without entries", found, len(entries), tt.found)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSnapshotOfAnotherPlayer(t *testing.T) {
+	store := NewStore(t.TempDir())
+	if err := store.Save("g", gamelogic.Snapshot{Player: gamelogic.Player{Username: "bob", Units: map[int]gamelogic.Unit{}}}); err != nil {
+		t.Fatal(err)
+	}
+	bob, err := store.Path("g", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := store.Path("g", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(bob, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := store.Load("g", "alice"); err == nil {
+		t.Error("loaded the snapshot of bob as alice")
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		game     string
+		username string
+		wantErr  bool
+	}{
+		{"valid", "g", "alice", false},
+		{"no game", "", "alice", true},
+		{"no username", "g", "", true},
+		{"dots", "..", "alice", true},
+		{"slash", "g", "a/b", true},
+		{"backslash", `g\h`, "alice", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore("state")
+			path, err := store.Path(tt.game, tt.username)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Path() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && path != filepath.Join("state", tt.game, tt.username+".json") {
+				t.Errorf("Path() = %s", path)
+			}
+		})
+	}
+}
+
+func TestLoadJournal(t *testing.T) {
+	spawned := gamelogic.JournalEntry{Seq: 1, Kind: gamelogic.JournalUnitSpawned, Unit: gamelogic.Unit{ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"}}
+	moved := gamelogic.JournalEntry{Seq: 2, Kind: gamelogic.JournalUnitMoved, Unit: gamelogic.Unit{ID: 1, Rank: gamelogic.RankInfantry, Location: "asia"}, From: "europe"}
+	tests := []struct {
+		name    string
+		tail    string
+		wantErr bool
+		want    []gamelogic.JournalEntry
+	}{
+		{
+			name: "whole lines",
+			want: []gamelogic.JournalEntry{spawned, moved},
+		},
+		{
+			name: "torn last line",
+			tail: `{"Seq":3,"Kind":"unit_mo`,
+			want: []gamelogic.JournalEntry{spawned, moved},
+		},
+		{
+			name: "corrupt last line",
+			tail: "not json\n",
+			want: []gamelogic.JournalEntry{spawned, moved},
+		},
+		{
+			name:    "corrupt line in the middle",
+			tail:    "not json\n{\"Seq\":3,\"Kind\":\"paused\"}\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore(t.TempDir())
+			for _, e := range []gamelogic.JournalEntry{spawned, moved} {
+				if err := store.Append("g", "alice", e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			path, err := store.JournalPath("g", "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteString(tt.tail); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			_, entries, found, err := store.Load("g", "alice")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !found || !reflect.DeepEqual(entries, tt.want) {
+				t.Fatalf("Load() = %+v, %v, want %+v", entries, found, tt.want)
+			}
+
+			// The next entry starts on a line of its own.
+			paused := gamelogic.JournalEntry{Seq: 3, Kind: gamelogic.JournalPaused}
+			if err := store.Append("g", "alice", paused); err != nil {
+				t.Fatal(err)
+			}
+			_, entries, _, err = store.Load("g", "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := append(tt.want, paused); !reflect.DeepEqual(entries, want) {
+				t.Errorf("Load() after Append() = %+v, want %+v", entries, want)
+			}
+		})
+	}
+}